@@ -0,0 +1,120 @@
+package tsv
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// NormalizeAddrs configures the reader to rewrite IPv6 addr and subnet
+// values in their canonical text form (RFC 5952), so that addresses written
+// differently by other tools compare equal. Values that cannot be parsed
+// are returned as written, with a warning. IPv4 values are returned as
+// written.
+func (r *Reader) NormalizeAddrs(b bool) *Reader {
+	r.converters[Addr], r.converters[Subnet] = nil, nil
+	if b {
+		r.converters[Addr], r.converters[Subnet] = r.toAddr, r.toSubnet
+	}
+	r.canonicalIPv4 = false
+	return r
+}
+
+// WithCanonicalAddr configures the reader to rewrite addr and subnet values
+// in their canonical text form, as NormalizeAddrs does, and IPv4 values
+// without leading zeros, so that addresses written differently by different
+// sensors compare equal.
+func (r *Reader) WithCanonicalAddr(b bool) *Reader {
+	r.NormalizeAddrs(b)
+	r.canonicalIPv4 = b
+	return r
+}
+
+// WithStrictAddrs configures the reader to fail with ErrConvert on addr and
+// subnet values that cannot be parsed when rewriting them in canonical
+// form, rather than return them as written.
+func (r *Reader) WithStrictAddrs(b bool) *Reader {
+	r.strictAddrs = b
+	return r
+}
+
+// toAddr converts input to a string holding the canonical form of an IPv6
+// address, or of an IPv4 address if configured.
+func (r *Reader) toAddr(b []byte) (interface{}, error) {
+	if bytes.IndexByte(b, ':') < 0 {
+		if !r.canonicalIPv4 {
+			return string(b), nil
+		}
+		if addr, ok := canonicalIPv4(b); ok {
+			return addr, nil
+		}
+		return r.unparseable("address", b)
+	}
+	ip := net.ParseIP(btos(b))
+	if ip == nil {
+		return r.unparseable("address", b)
+	}
+	return formatIPv6(ip), nil
+}
+
+// toSubnet converts input to a string holding the canonical form of an IPv6
+// subnet, or of an IPv4 subnet if configured.
+func (r *Reader) toSubnet(b []byte) (interface{}, error) {
+	ipv6 := bytes.IndexByte(b, ':') >= 0
+	if !ipv6 && !r.canonicalIPv4 {
+		return string(b), nil
+	}
+	if i := bytes.IndexByte(b, '/'); i >= 0 {
+		bits, err := strconv.ParseUint(btos(b[i+1:]), 10, 8)
+		if ipv6 {
+			ip := net.ParseIP(btos(b[:i]))
+			if ip != nil && err == nil && bits <= 128 {
+				return formatIPv6(ip) + "/" + strconv.FormatUint(bits, 10), nil
+			}
+		} else if addr, ok := canonicalIPv4(b[:i]); ok && err == nil && bits <= 32 {
+			return addr + "/" + strconv.FormatUint(bits, 10), nil
+		}
+	}
+	return r.unparseable("subnet", b)
+}
+
+// unparseable returns an addr or subnet value that could not be parsed as
+// written, with a warning, or an error if configured with WithStrictAddrs.
+func (r *Reader) unparseable(kind string, b []byte) (interface{}, error) {
+	if r.strictAddrs {
+		return nil, fmt.Errorf("%w: %s %q", ErrConvert, kind, b)
+	}
+	r.warn("unparseable "+kind, "value", snippet(b))
+	return string(b), nil
+}
+
+// canonicalIPv4 formats a dotted-decimal IPv4 address without leading
+// zeros, reporting whether b is one.
+func canonicalIPv4(b []byte) (string, bool) {
+	parts := bytes.Split(b, []byte("."))
+	if len(parts) != 4 {
+		return "", false
+	}
+	addr := make([]byte, 0, len(b))
+	for i, part := range parts {
+		n, err := strconv.ParseUint(btos(part), 10, 8)
+		if err != nil {
+			return "", false
+		}
+		if i > 0 {
+			addr = append(addr, '.')
+		}
+		addr = strconv.AppendUint(addr, n, 10)
+	}
+	return string(addr), true
+}
+
+// formatIPv6 formats an address parsed from IPv6 text, keeping IPv4-mapped
+// addresses in IPv6 form.
+func formatIPv6(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return "::ffff:" + ip4.String()
+	}
+	return ip.String()
+}
@@ -0,0 +1,93 @@
+package tsv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeAddrs(t *testing.T) {
+	var tests = []struct {
+		typ, in, out string
+	}{
+		{"addr", "1.1.1.1", "1.1.1.1"},
+		{"addr", "2001:DB8:0:0:0:0:0:0001", "2001:db8::1"},
+		{"addr", "2001:0db8::0001", "2001:db8::1"},
+		{"addr", "::FFFF:10.0.0.1", "::ffff:10.0.0.1"},
+		{"addr", "2001:db8::zz", "2001:db8::zz"},
+		{"subnet", "10.0.0.0/8", "10.0.0.0/8"},
+		{"subnet", "2001:DB8:0::/32", "2001:db8::/32"},
+		{"subnet", "2001:db8::/129", "2001:db8::/129"},
+		{"subnet", "2001:db8::", "2001:db8::"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			in := strings.Replace(input, "addr", tt.typ, 1)
+			in = strings.Replace(in, "\t1.1.1.1\t", "\t"+tt.in+"\t", 1)
+			var warnings int
+			reader := NewReader(strings.NewReader(in)).NormalizeAddrs(true).
+				WithLogger(func(level, msg string, kv ...interface{}) { warnings++ })
+			record, err := reader.Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record["id.orig_h"] != tt.out {
+				t.Errorf("expected %q, got %q", tt.out, record["id.orig_h"])
+			}
+			if (tt.in == tt.out && strings.Contains(tt.in, ":")) != (warnings == 1) {
+				t.Errorf("unexpected %d warnings", warnings)
+			}
+		})
+	}
+}
+
+func TestCanonicalAddr(t *testing.T) {
+	var tests = []struct {
+		typ, in, out string
+		ok           bool
+	}{
+		{"addr", "1.1.1.1", "1.1.1.1", true},
+		{"addr", "010.001.000.001", "10.1.0.1", true},
+		{"addr", "2001:0db8::0001", "2001:db8::1", true},
+		{"addr", "10.0.0.256", "10.0.0.256", false},
+		{"addr", "10.0.0", "10.0.0", false},
+		{"subnet", "010.000.0.0/08", "10.0.0.0/8", true},
+		{"subnet", "10.0.0.0/33", "10.0.0.0/33", false},
+		{"subnet", "2001:DB8:0::/32", "2001:db8::/32", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			in := strings.Replace(input, "addr", tt.typ, 1)
+			in = strings.Replace(in, "\t1.1.1.1\t", "\t"+tt.in+"\t", 1)
+			record, err := NewReader(strings.NewReader(in)).WithCanonicalAddr(true).Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record["id.orig_h"] != tt.out {
+				t.Errorf("expected %q, got %q", tt.out, record["id.orig_h"])
+			}
+			_, err = NewReader(strings.NewReader(in)).WithCanonicalAddr(true).WithStrictAddrs(true).Read()
+			if tt.ok != (err == nil) || err != nil && !errors.Is(err, ErrConvert) {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func BenchmarkNormalizeAddrs(b *testing.B) {
+	row := input[strings.Index(input, "\n1546304400")+1:]
+	row = row[:strings.IndexByte(row, '\n')+1]
+	in := input[:strings.Index(input, row)] + strings.Repeat(row, 1000)
+	for _, normalize := range []bool{false, true} {
+		b.Run(map[bool]string{false: "raw", true: "ipv4"}[normalize], func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				reader := NewReader(strings.NewReader(in)).NormalizeAddrs(normalize)
+				for {
+					if _, err := reader.Read(); err != nil {
+						break
+					}
+				}
+			}
+		})
+	}
+}
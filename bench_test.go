@@ -0,0 +1,26 @@
+package tsv_test
+
+import (
+	"testing"
+
+	tsv "github.com/0xcc-labs/zeek-tsv"
+	"github.com/0xcc-labs/zeek-tsv/tsvgen"
+)
+
+func BenchmarkRead(b *testing.B) {
+	for _, log := range tsvgen.Logs {
+		data, err := tsvgen.Bytes(tsvgen.Config{Log: log, Rows: 10000, Seed: 1})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(log, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := tsv.NewBytesReader(data).ReadAll(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package tsv
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// HeaderCache shares the fields and types of headers between readers of
+// logs with the same schema, such as many small logs rotated every minute,
+// sparing their parsing and allocation. A HeaderCache is safe for
+// concurrent use. Readers sharing a cache must be configured with the same
+// key transform and field renames, and must not modify header Fields or
+// Types.
+type HeaderCache struct {
+	mu     sync.Mutex
+	size   int
+	fields map[string]cachedFields
+	types  map[string]cachedTypes
+}
+
+type cachedFields struct {
+	fields   []string
+	original []string
+}
+
+type cachedTypes struct {
+	types []FieldType
+	names []string
+}
+
+// NewHeaderCache creates a cache of up to size distinct #fields and #types
+// lines each.
+func NewHeaderCache(size int) *HeaderCache {
+	return &HeaderCache{
+		size:   size,
+		fields: make(map[string]cachedFields),
+		types:  make(map[string]cachedTypes),
+	}
+}
+
+// WithHeaderCache configures the reader to share header fields and types
+// with other readers through cache.
+func (r *Reader) WithHeaderCache(cache *HeaderCache) *Reader {
+	r.headerCache = cache
+	return r
+}
+
+// addCachedFields adds the fields of the #fields line to header, reusing
+// those of an identical line read before.
+func (r *Reader) addCachedFields(header *Header, line []byte, row Row) error {
+	c := r.headerCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.fields[string(line)]; ok {
+		header.Fields, header.originalFields = cached.fields, cached.original
+		return nil
+	}
+	if err := r.addFields(header, row); err != nil {
+		return err
+	}
+	if len(c.fields) >= c.size {
+		for k := range c.fields {
+			delete(c.fields, k)
+			break
+		}
+	}
+	c.fields[string(line)] = cachedFields{header.Fields, header.originalFields}
+	return nil
+}
+
+// addCachedTypes adds the types of the #types line to header, reusing those
+// of an identical line read before.
+func (r *Reader) addCachedTypes(header *Header, line []byte, row Row) error {
+	c := r.headerCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.types[string(line)]; ok {
+		header.Types, header.typeNames = cached.types, cached.names
+		return nil
+	}
+	if err := addTypes(header, row); err != nil {
+		return err
+	}
+	if len(c.types) >= c.size {
+		for k := range c.types {
+			delete(c.types, k)
+			break
+		}
+	}
+	c.types[string(line)] = cachedTypes{header.Types, header.typeNames}
+	return nil
+}
+
+// WithKnownHeader configures the reader to skip the header of the log
+// rather than parse it, taking h, read before from a log with the same
+// schema and header length by a reader configured the same way, as its
+// header. The header is only checked to be h.Length bytes of lines starting
+// with '#'; if it is not, or cannot be looked ahead at, it is parsed as
+// usual. The #path and #open values, which differ between files, are read
+// from the skipped lines rather than taken from h.
+func (r *Reader) WithKnownHeader(h *Header) *Reader {
+	r.knownHeader = h
+	return r
+}
+
+// skipKnownHeader skips the header as configured by WithKnownHeader,
+// leaving the first data row current. It returns a nil header if the input
+// does not seem to start with the known header.
+func (r *Reader) skipKnownHeader() (*Header, error) {
+	h := r.knownHeader
+	marker := r.marker()
+	b := r.parser.peek(int(h.Length) + len(marker))
+	if uint64(len(b)) < h.Length || h.Length == 0 || b[0] != '#' || b[h.Length-1] != '\n' {
+		return nil, nil
+	}
+	if next := b[h.Length:]; len(next) > 0 && next[0] == '#' && !bytes.Equal(next, marker) {
+		return nil, nil
+	}
+	if err := r.retain(int(h.Length), "header"); err != nil {
+		return nil, err
+	}
+	header := h.copy()
+	header.Path, header.Open, header.OpenLayout, header.Close = "", time.Time{}, "", time.Time{}
+	r.readFileDirectives(header, b[:h.Length])
+	header.raw = nil
+	if !r.discardRaw {
+		header.raw = append(header.raw, b[:h.Length]...)
+	}
+	if err := r.parser.skip(h.Length); err != nil {
+		return nil, err
+	}
+	r.parser.Delimiter = h.Separator
+	row, err := r.parser.Read()
+	if err != nil && !(err == io.EOF && row != nil) {
+		return nil, err
+	}
+	return header, nil
+}
+
+// readFileDirectives sets the #path and #open values of header from the
+// header lines in b.
+func (r *Reader) readFileDirectives(header *Header, b []byte) {
+	path := []byte("#path" + string(header.Separator))
+	open := []byte("#open" + string(header.Separator))
+	for len(b) > 0 {
+		line := b
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			line, b = b[:i], b[i+1:]
+		} else {
+			b = nil
+		}
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		switch {
+		case bytes.HasPrefix(line, path):
+			header.Path = string(line[len(path):])
+		case bytes.HasPrefix(line, open):
+			value := line[len(open):]
+			header.Open, header.OpenLayout = r.parseTime(string(value))
+			if header.OpenLayout == "" {
+				r.warn("unparseable time", "field", "#open", "value", snippet(value))
+			}
+		}
+	}
+}
+
+// sameSchema reports whether a and b have the same fields and types.
+func sameSchema(a, b *Header) bool {
+	return reflect.DeepEqual(a.Fields, b.Fields) && reflect.DeepEqual(a.Types, b.Types)
+}
@@ -0,0 +1,151 @@
+package tsv
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHeaderCache(t *testing.T) {
+	cache := NewHeaderCache(1)
+	var wg sync.WaitGroup
+	headers := make([]*Header, 8)
+	for i := range headers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reader := NewReader(strings.NewReader(input)).WithHeaderCache(cache)
+			if records := collect(reader); len(records) != 3 {
+				t.Errorf("expected 3 records, got %d", len(records))
+			}
+			headers[i] = reader.Header()
+		}(i)
+	}
+	wg.Wait()
+	for _, header := range headers[1:] {
+		if &header.Fields[0] != &headers[0].Fields[0] || &header.Types[0] != &headers[0].Types[0] {
+			t.Fatal("expected fields and types to be shared")
+		}
+	}
+
+	other := strings.Replace(input, "\tdurations", "\tintervals", 1)
+	reader := NewReader(strings.NewReader(other)).WithHeaderCache(cache)
+	if _, err := reader.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if reader.Header().Fields[10] != "intervals" {
+		t.Errorf("expected field intervals, got %s", reader.Header().Fields[10])
+	}
+	if len(cache.fields) != 1 || len(cache.types) != 1 {
+		t.Errorf("expected the cache to hold 1 entry, got %d and %d", len(cache.fields), len(cache.types))
+	}
+
+	ecs := NewReader(strings.NewReader(other)).WithHeaderCache(cache).WithECSMapping()
+	if _, err := ecs.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if fields := cache.fields[strings.Split(other, "\n")[6]+"\n"].fields; fields[2] != "id.orig_h" {
+		t.Errorf("expected the ECS mapping to leave cached fields unchanged, got %v", fields)
+	}
+}
+
+func TestKnownHeader(t *testing.T) {
+	reader := NewReader(strings.NewReader(input))
+	expected := collect(reader)
+	known := reader.Header()
+
+	// A header of the same length is skipped, but its path and open time are
+	// those of the log read.
+	same := strings.Replace(input, "#path\ttest", "#path\tpath", 1)
+	same = strings.Replace(same, "#open\t2019-01-01-00-00-00", "#open\t2020-05-05-05-05-05", 1)
+	open := time.Date(2020, 5, 5, 5, 5, 5, 0, time.UTC)
+	for _, reader := range []*Reader{
+		NewReader(strings.NewReader(same)).WithKnownHeader(known),
+		NewBytesReader([]byte(same)).WithKnownHeader(known),
+	} {
+		records := collect(reader)
+		if len(records) != len(expected) {
+			t.Fatalf("expected %d records, got %d", len(expected), len(records))
+		}
+		for i, record := range records {
+			if eq, diffs := RecordsEqual(expected[i], record, NilEqualsMissing()); !eq {
+				t.Errorf("record %d: %v", i, diffs)
+			}
+		}
+		if reader.Header().Path != "path" || reader.Header() == known {
+			t.Errorf("expected a copy of the known header with path path, got %s", reader.Header().Path)
+		}
+		if !reader.Header().Open.Equal(open) || known.Open.Equal(open) {
+			t.Errorf("expected open time %v, got %v", open, reader.Header().Open)
+		}
+		if !reader.Result().FooterSeen || !known.Close.Equal(reader.Header().Close) {
+			t.Error("expected the footer to be read")
+		}
+	}
+
+	// Other headers are parsed.
+	var warnings []string
+	logger := func(level, msg string, kv ...interface{}) {
+		warnings = append(warnings, msg)
+	}
+	other := strings.Replace(input, "\tdurations", "\tdurs", 1)
+	reader = NewReader(strings.NewReader(other)).WithKnownHeader(known).WithLogger(logger)
+	if _, err := reader.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if reader.Header().Fields[10] != "durs" {
+		t.Errorf("expected field durs, got %s", reader.Header().Fields[10])
+	}
+	if len(warnings) != 1 {
+		t.Errorf("expected a warning about the header, got %q", warnings)
+	}
+}
+
+func TestKnownHeaderConcurrent(t *testing.T) {
+	known := NewReader(strings.NewReader(input)).Header()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reader := NewReader(strings.NewReader(input)).WithKnownHeader(known)
+			if records := collect(reader); len(records) != 3 {
+				t.Errorf("expected 3 records, got %d", len(records))
+			}
+			if _, ok := known.FieldIndex("uid"); !ok || len(known.Columns()) != 11 {
+				t.Error("expected the known header to describe its fields")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkHeaderCache(b *testing.B) {
+	cache := NewHeaderCache(16)
+	known := NewBytesReader([]byte(input)).Header()
+	for _, bc := range []struct {
+		name  string
+		cache *HeaderCache
+	}{
+		{"none", nil},
+		{"cache", cache},
+		{"known", nil},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				reader := NewBytesReader([]byte(input))
+				if bc.cache != nil {
+					reader.WithHeaderCache(bc.cache)
+				}
+				if bc.name == "known" {
+					reader.WithKnownHeader(known)
+				}
+				if _, err := reader.Read(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	zeek "github.com/0xcc-labs/zeek-tsv"
+)
+
+// loadECSMappings merges the mappings stored in file into the built-in
+// zeek.ECSMappings, field by field.
+func loadECSMappings(file string) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var overrides map[string]map[string]zeek.ECSField
+	if err := json.Unmarshal(b, &overrides); err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	for path, fields := range overrides {
+		if zeek.ECSMappings[path] == nil {
+			zeek.ECSMappings[path] = make(map[string]zeek.ECSField, len(fields))
+		}
+		for k, v := range fields {
+			zeek.ECSMappings[path][k] = v
+		}
+	}
+	return nil
+}
+
+// ecsNamespace returns the renames moving the fields left unmapped by the
+// ECS mapping under zeek.<path>.
+func ecsNamespace(header *zeek.Header) map[string]string {
+	fields := zeek.ECSMappings[header.Path]
+	keys := make(map[string]string)
+	for _, c := range header.Columns() {
+		if _, ok := fields[c.OriginalName]; !ok {
+			keys[c.Name] = "zeek." + header.Path + "." + xformKey(c.OriginalName)
+		}
+	}
+	return keys
+}
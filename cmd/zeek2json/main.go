@@ -2,6 +2,8 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"flag"
 	"io"
 	"log"
 	"os"
@@ -12,25 +14,157 @@ import (
 	zeek "github.com/0xcc-labs/zeek-tsv"
 )
 
+var (
+	ecs      = flag.Bool("ecs", false, "rename fields to the Elastic Common Schema")
+	ecsMap   = flag.String("ecs-map", "", "JSON `file` overriding the built-in ECS mappings")
+	numText  = flag.Bool("numeric-text", false, "emit time, interval and double values as written in the log")
+	progress = flag.Bool("progress", false, "report progress on stderr")
+	zeekFmt  = flag.Bool("zeek-float-format", false, "format time, interval and double values as zeek writes them")
+	offsets  = flag.Bool("with-offsets", false, "add the byte offset and line number of each record in the log")
+	offField = flag.String("offset-field", "_offset", "`name` of the field holding the byte offset with -with-offsets")
+	lineFld  = flag.String("line-field", "_line", "`name` of the field holding the line number with -with-offsets")
+	seek     = flag.Uint64("seek", 0, "start with the first record at or after byte `offset`; the line number is then omitted")
+	count    = flag.Uint64("count", 0, "stop after `n` records")
+	jsonFlds = flag.String("json-fields", "", "comma-separated `fields` holding JSON, emitted as nested values")
+)
+
+// progressInterval is the number of records between progress reports.
+const progressInterval = 100000
+
 func main() {
-	out := bufio.NewWriter(os.Stdout)
+	flag.Parse()
+	if err := run(os.Stdin, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run converts the log read from in to JSON records written to w.
+func run(in io.Reader, w io.Writer) error {
+	out := bufio.NewWriter(w)
 	defer out.Flush()
 
-	reader := zeek.NewReader(os.Stdin).WithKeyTransform(xformKey).OmitEmpty(true)
+	reader := zeek.NewReader(in).OmitEmpty(true).PreserveNumericText(*numText)
+	if *ecs {
+		if *ecsMap != "" {
+			if err := loadECSMappings(*ecsMap); err != nil {
+				return err
+			}
+		}
+		reader.WithECSMapping()
+	} else {
+		reader.WithKeyTransform(xformKey)
+	}
+	if *jsonFlds != "" {
+		for _, field := range strings.Split(*jsonFlds, ",") {
+			reader.WithColumnConverter(field, zeek.ToJSONRaw)
+		}
+	}
+	var total int64
+	if f, ok := in.(*os.File); ok {
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+			total = fi.Size()
+		}
+	}
+	if *seek > 0 {
+		if _, err := reader.ReadRange(*seek, *seek); err != nil {
+			return err
+		}
+	}
+	var namespace map[string]string
+	var floatFormats map[string]floatFormat
 	encoder := gojay.NewEncoder(out)
-	for {
+	for n := uint64(0); *count == 0 || n < *count; n++ {
 		record, err := reader.Read()
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
-			log.Fatal(err)
+			return err
+		}
+		if *progress && reader.RecordsRead()%progressInterval == 0 {
+			reportProgress(reader, total)
+		}
+		if *offsets {
+			record[*offField] = reader.RecordOffset()
+			if *seek == 0 {
+				record[*lineFld] = reader.LineNumber()
+			}
+		}
+		if *zeekFmt {
+			if floatFormats == nil {
+				floatFormats = zeekFloatFormats(reader.Columns())
+			}
+			formatFloats(record, floatFormats)
+		}
+		if *ecs {
+			if namespace == nil {
+				namespace = ecsNamespace(reader.Header())
+			}
+			for from, to := range namespace {
+				if v, ok := record[from]; ok {
+					delete(record, from)
+					record[to] = v
+				}
+			}
 		}
 		if err := encoder.Encode(jsonRecord(record)); err != nil {
-			log.Fatal(err)
+			return err
 		}
 		out.WriteByte('\n')
 	}
+	if *progress {
+		reportProgress(reader, total)
+	}
+	return nil
+}
+
+// reportProgress logs how much of an input of total bytes has been read, or
+// the number of bytes read if total is unknown.
+func reportProgress(reader *zeek.Reader, total int64) {
+	if total > 0 {
+		log.Printf("%.1f%% of input, %d records", 100*reader.Progress(total), reader.RecordsRead())
+	} else {
+		log.Printf("%d bytes, %d records", reader.BytesRead(), reader.RecordsRead())
+	}
+}
+
+// A floatFormat appends the text of a float64 value.
+type floatFormat func(b []byte, f float64) []byte
+
+// zeekFloatFormats returns the zeek formatting of the time, interval and
+// double columns, keyed by field name.
+func zeekFloatFormats(columns []zeek.Column) map[string]floatFormat {
+	formats := make(map[string]floatFormat)
+	for _, c := range columns {
+		typ := c.Type
+		if i := strings.IndexByte(typ, '['); i >= 0 {
+			typ = strings.TrimSuffix(typ[i+1:], "]")
+		}
+		switch typ {
+		case "time", "interval":
+			formats[c.Name] = zeek.AppendZeekInterval
+		case "double":
+			formats[c.Name] = zeek.AppendZeekDouble
+		}
+	}
+	return formats
+}
+
+// formatFloats replaces the float64 values of record, including container
+// elements, by Numbers formatted as given by formats.
+func formatFloats(record zeek.Record, formats map[string]floatFormat) {
+	for k, format := range formats {
+		switch v := record[k].(type) {
+		case float64:
+			record[k] = zeek.Number(format(nil, v))
+		case []interface{}:
+			for i, e := range v {
+				if f, ok := e.(float64); ok {
+					v[i] = zeek.Number(format(nil, f))
+				}
+			}
+		}
+	}
 }
 
 func xformKey(key string) string {
@@ -41,11 +175,18 @@ type jsonRecord zeek.Record
 
 func (r jsonRecord) MarshalJSONObject(enc *gojay.Encoder) {
 	for k, v := range r {
-		if v, ok := v.([]interface{}); ok {
+		switch v := v.(type) {
+		case []interface{}:
 			enc.AddInterfaceKey(k, jsonArray(v))
-			continue
+		case zeek.Number:
+			n := numberJSON(v)
+			enc.AddEmbeddedJSONKey(k, &n)
+		case json.RawMessage:
+			n := gojay.EmbeddedJSON(v)
+			enc.AddEmbeddedJSONKey(k, &n)
+		default:
+			enc.AddInterfaceKey(k, v)
 		}
-		enc.AddInterfaceKey(k, v)
 	}
 }
 
@@ -57,6 +198,11 @@ type jsonArray []interface{}
 
 func (a jsonArray) MarshalJSONArray(enc *gojay.Encoder) {
 	for _, v := range a {
+		if v, ok := v.(zeek.Number); ok {
+			n := numberJSON(v)
+			enc.AddEmbeddedJSON(&n)
+			continue
+		}
 		enc.AddInterface(v)
 	}
 }
@@ -64,3 +210,12 @@ func (a jsonArray) MarshalJSONArray(enc *gojay.Encoder) {
 func (a jsonArray) IsNil() bool {
 	return len(a) == 0
 }
+
+// numberJSON returns the JSON encoding of n, null if it has none.
+func numberJSON(n zeek.Number) gojay.EmbeddedJSON {
+	b, err := n.MarshalJSON()
+	if err != nil {
+		return gojay.EmbeddedJSON("null")
+	}
+	return gojay.EmbeddedJSON(b)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testLog = "#separator \\x09\n" +
+	"#set_separator\t,\n" +
+	"#empty_field\t(empty)\n" +
+	"#unset_field\t-\n" +
+	"#path\tconn\n" +
+	"#fields\tuid\tbytes\n" +
+	"#types\tstring\tcount\n" +
+	"a\t1\n" +
+	"b\t2\n" +
+	"c\t3\n" +
+	"#close\t2019-01-01-00-00-01\n"
+
+// convert runs the conversion of testLog with the flags set as given, and
+// returns the records written.
+func convert(t *testing.T, flags map[string]string) []map[string]interface{} {
+	t.Helper()
+	for name, value := range flags {
+		f := flag.Lookup(name)
+		t.Cleanup(func() { f.Value.Set(f.DefValue) })
+		if err := f.Value.Set(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out bytes.Buffer
+	if err := run(strings.NewReader(testLog), &out); err != nil {
+		t.Fatal(err)
+	}
+	var records []map[string]interface{}
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var record map[string]interface{}
+		if err := dec.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestOffsets(t *testing.T) {
+	records := convert(t, map[string]string{"with-offsets": "true"})
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	for i, record := range records {
+		offset := strings.Index(testLog, record["uid"].(string)+"\t")
+		if record["_offset"] != float64(offset) {
+			t.Errorf("record %d: expected offset %d, got %v", i, offset, record["_offset"])
+		}
+		if record["_line"] != float64(8+i) {
+			t.Errorf("record %d: expected line %d, got %v", i, 8+i, record["_line"])
+		}
+	}
+}
+
+func TestSeekCount(t *testing.T) {
+	second := strings.Index(testLog, "b\t")
+	var tests = []struct {
+		name  string
+		flags map[string]string
+		uids  []string
+	}{
+		{"count", map[string]string{"count": "2"}, []string{"a", "b"}},
+		{"seek to a record", map[string]string{"seek": strconv.Itoa(second)}, []string{"b", "c"}},
+		{"seek inside a record", map[string]string{"seek": strconv.Itoa(second - 1)}, []string{"b", "c"}},
+		{"seek and count", map[string]string{"seek": strconv.Itoa(second), "count": "1"}, []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.flags["with-offsets"] = "true"
+			var uids []string
+			for _, record := range convert(t, tt.flags) {
+				uids = append(uids, record["uid"].(string))
+				if _, ok := record["_line"]; ok && tt.flags["seek"] != "" {
+					t.Errorf("expected no line number after seeking, got %v", record["_line"])
+				}
+			}
+			if strings.Join(uids, ",") != strings.Join(tt.uids, ",") {
+				t.Errorf("expected %v, got %v", tt.uids, uids)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+// Command zeek2sqlite loads zeek logs into a SQLite database, creating one
+// table per log path.
+//
+// Usage:
+//
+//	zeek2sqlite [-o file.db] [-batch n] [-index=false] [-progress] log...
+//
+// Flags may also follow the logs, as in "zeek2sqlite conn.log dns.log -o
+// case.db".
+//
+// It lives in its own module so that the SQLite driver does not become a
+// dependency of the library.
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "modernc.org/sqlite"
+
+	zeek "github.com/0xcc-labs/zeek-tsv"
+)
+
+var (
+	output   = flag.String("o", "zeek.db", "database `file` to write to")
+	batch    = flag.Int("batch", 10000, "number of rows inserted per transaction")
+	index    = flag.Bool("index", true, "create indices on the ts and uid columns")
+	progress = flag.Bool("progress", false, "report progress on stderr")
+)
+
+// progressInterval is the number of records between progress reports.
+const progressInterval = 100000
+
+func main() {
+	logs := parseArgs(flag.CommandLine, os.Args[1:])
+	if len(logs) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: zeek2sqlite [-o file.db] log...")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	if *batch < 1 {
+		fmt.Fprintln(os.Stderr, "zeek2sqlite: -batch must be at least 1")
+		os.Exit(2)
+	}
+
+	db, err := sql.Open("sqlite", *output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, path := range logs {
+		n, err := load(db, path)
+		if err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+		log.Printf("%s: %d records", path, n)
+	}
+}
+
+// parseArgs parses the flags in args, which may precede or follow the logs,
+// and returns the logs. Arguments following "--" are all logs.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var logs []string
+	for {
+		fs.Parse(args)
+		rest := fs.Args()
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			return append(logs, rest...)
+		}
+		if len(rest) == 0 {
+			return logs
+		}
+		logs = append(logs, rest[0])
+		args = rest[1:]
+	}
+}
+
+// load inserts the records of the log at path into the table named after
+// its #path, creating the table if needed.
+func load(db *sql.DB, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var total int64
+	if fi, err := f.Stat(); err == nil {
+		total = fi.Size()
+	}
+
+	reader := zeek.NewReader(f)
+	record, err := reader.Read()
+	if err == io.EOF {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	header := reader.Header()
+	table := header.Path
+	if table == "" {
+		table = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	columns := reader.Columns()
+	if err := createTable(db, table, columns); err != nil {
+		return 0, err
+	}
+
+	names := make([]string, len(columns))
+	params := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = quote(c.Name)
+		params[i] = "?"
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quote(table), strings.Join(names, ", "), strings.Join(params, ", "))
+
+	var tx *sql.Tx
+	var stmt *sql.Stmt
+	values := make([]interface{}, len(columns))
+	n := 0
+	for ; err == nil; record, err = reader.Read() {
+		if tx == nil {
+			if tx, err = db.Begin(); err != nil {
+				return n, err
+			}
+			if stmt, err = tx.Prepare(insert); err != nil {
+				tx.Rollback()
+				return n, err
+			}
+		}
+		for i, c := range columns {
+			if values[i], err = sqlValue(record[c.Name]); err != nil {
+				tx.Rollback()
+				return n, fmt.Errorf("%s: %v", c.Name, err)
+			}
+		}
+		if _, err = stmt.Exec(values...); err != nil {
+			tx.Rollback()
+			return n, err
+		}
+		n++
+		if *progress && n%progressInterval == 0 {
+			log.Printf("%s: %.1f%%, %d records", path, 100*reader.Progress(total), n)
+		}
+		if n%*batch == 0 {
+			if err = tx.Commit(); err != nil {
+				return n, err
+			}
+			tx = nil
+		}
+	}
+	if err != io.EOF {
+		if tx != nil {
+			tx.Rollback()
+		}
+		return n, err
+	}
+	if tx != nil {
+		if err := tx.Commit(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// createTable creates the table for a log, or checks that the existing
+// table has the same columns.
+func createTable(db *sql.DB, table string, columns []zeek.Column) error {
+	existing, err := tableColumns(db, table)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return checkSchema(table, existing, columns)
+	}
+
+	defs := make([]string, len(columns))
+	for i, c := range columns {
+		defs[i] = quote(c.Name) + " " + sqlType(c)
+	}
+	stmt := fmt.Sprintf("CREATE TABLE %s (%s)", quote(table), strings.Join(defs, ", "))
+	if _, err := db.Exec(stmt); err != nil {
+		return err
+	}
+	if !*index {
+		return nil
+	}
+	for _, c := range columns {
+		if c.Name != "ts" && c.Name != "uid" {
+			continue
+		}
+		stmt := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)",
+			quote(table+"_"+c.Name), quote(table), quote(c.Name))
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type sqlColumn struct {
+	name, typ string
+}
+
+// tableColumns returns the columns of table, or nil if it does not exist.
+func tableColumns(db *sql.DB, table string) ([]sqlColumn, error) {
+	rows, err := db.Query("SELECT name, type FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []sqlColumn
+	for rows.Next() {
+		var c sqlColumn
+		if err := rows.Scan(&c.name, &c.typ); err != nil {
+			return nil, err
+		}
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
+
+func checkSchema(table string, existing []sqlColumn, columns []zeek.Column) error {
+	if len(existing) != len(columns) {
+		return fmt.Errorf("schema conflict: table %s has %d columns, log has %d",
+			table, len(existing), len(columns))
+	}
+	for i, c := range columns {
+		if existing[i].name != c.Name || !strings.EqualFold(existing[i].typ, sqlType(c)) {
+			return fmt.Errorf("schema conflict: table %s column %d is %s %s, log has %s %s",
+				table, i+1, existing[i].name, existing[i].typ, c.Name, sqlType(c))
+		}
+	}
+	return nil
+}
+
+func sqlType(c zeek.Column) string {
+	if c.Container {
+		return "TEXT"
+	}
+	switch c.Type {
+	case "time", "interval", "double":
+		return "REAL"
+	case "count", "int", "port", "bool":
+		return "INTEGER"
+	}
+	return "TEXT"
+}
+
+// sqlValue converts a record value to a type accepted by the driver.
+// Containers are stored as JSON arrays.
+func sqlValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case []interface{}:
+		b, err := json.Marshal(v)
+		return string(b), err
+	case uint16:
+		return int64(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return nil, fmt.Errorf("count %d overflows INTEGER", v)
+		}
+		return int64(v), nil
+	}
+	return v, nil
+}
+
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
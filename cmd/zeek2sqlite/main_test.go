@@ -0,0 +1,105 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+
+	zeek "github.com/0xcc-labs/zeek-tsv"
+)
+
+const testLog = "#separator \\x09\n" +
+	"#set_separator\t,\n" +
+	"#empty_field\t(empty)\n" +
+	"#unset_field\t-\n" +
+	"#path\tconn\n" +
+	"#fields\tts\tuid\tid.orig_h\tid.orig_p\tduration\tbytes\tlocal\tservice\ttags\n" +
+	"#types\ttime\tstring\taddr\tport\tinterval\tcount\tbool\tenum\tset[string]\n" +
+	"1546304400.000001\tCCb2Mx28qOMGD3hxab\t1.1.1.1\t80\t1.5\t1001\tT\thttp\ta,b\n"
+
+func testColumns(t *testing.T, log string) []zeek.Column {
+	reader := zeek.NewReader(strings.NewReader(log))
+	if _, err := reader.Read(); err != nil {
+		t.Fatal(err)
+	}
+	return reader.Columns()
+}
+
+func TestSQLType(t *testing.T) {
+	var types []string
+	for _, c := range testColumns(t, testLog) {
+		types = append(types, sqlType(c))
+	}
+	expected := []string{"REAL", "TEXT", "TEXT", "INTEGER", "REAL", "INTEGER", "INTEGER", "TEXT", "TEXT"}
+	if !reflect.DeepEqual(types, expected) {
+		t.Errorf("expected %v, got %v", expected, types)
+	}
+}
+
+func TestSchemaConflict(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	columns := testColumns(t, testLog)
+	if err := createTable(db, "conn", columns); err != nil {
+		t.Fatal(err)
+	}
+	// Appending a log of the same schema is fine.
+	if err := createTable(db, "conn", columns); err != nil {
+		t.Errorf("expected the same schema to be accepted, got %v", err)
+	}
+
+	var tests = []struct {
+		name, log, err string
+	}{
+		{
+			"column count",
+			strings.Replace(strings.Replace(testLog, "\ttags\n", "\n", 1), "\tset[string]\n", "\n", 1),
+			"schema conflict: table conn has 9 columns, log has 8",
+		},
+		{
+			"column name",
+			strings.Replace(testLog, "\tuid\t", "\tuuid\t", 1),
+			"schema conflict: table conn column 2 is uid TEXT, log has uuid TEXT",
+		},
+		{
+			"column type",
+			strings.Replace(testLog, "\tinterval\t", "\tstring\t", 1),
+			"schema conflict: table conn column 5 is duration REAL, log has duration TEXT",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := createTable(db, "conn", testColumns(t, tt.log))
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("expected %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	var tests = []struct {
+		args, logs []string
+		output     string
+	}{
+		{[]string{"-o", "case.db", "conn.log", "dns.log"}, []string{"conn.log", "dns.log"}, "case.db"},
+		{[]string{"conn.log", "dns.log", "-o", "case.db"}, []string{"conn.log", "dns.log"}, "case.db"},
+		{[]string{"conn.log", "-o", "case.db", "dns.log"}, []string{"conn.log", "dns.log"}, "case.db"},
+		{[]string{"conn.log", "--", "-o"}, []string{"conn.log", "-o"}, "zeek.db"},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("zeek2sqlite", flag.ContinueOnError)
+		output := fs.String("o", "zeek.db", "")
+		logs := parseArgs(fs, tt.args)
+		if !reflect.DeepEqual(logs, tt.logs) || *output != tt.output {
+			t.Errorf("%q: expected %q and %s, got %q and %s", tt.args, tt.logs, tt.output, logs, *output)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package tsv
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A conformanceReader reads every record of a log with one of the reader
+// implementations.
+type conformanceReader func(t *testing.T, path string) []Record
+
+var conformanceReaders = map[string]conformanceReader{
+	"eager": func(t *testing.T, path string) []Record {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		return readAll(t, NewReader(f))
+	},
+	"bytes": func(t *testing.T, path string) []Record {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return readAll(t, NewBytesReader(b))
+	},
+	"mmap": func(t *testing.T, path string) []Record {
+		reader, closer, err := OpenMmap(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closer()
+		return readAll(t, reader)
+	},
+	"range": func(t *testing.T, path string) []Record {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		records, err := NewReader(f).ReadRange(0, math.MaxUint64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return records
+	},
+	"interchange": func(t *testing.T, path string) []Record {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		reader := NewReader(f)
+		records := readAll(t, reader)
+		header := reader.Header()
+
+		var b bytes.Buffer
+		w := NewInterchangeWriter(&b, header)
+		for _, record := range records {
+			if err := w.Write(record); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		return readAll(t, NewInterchangeReader(&b).WithSentinels(header.Unset, header.Empty, header.SetSeparator))
+	},
+}
+
+// TestConformance runs every reader over the logs in testdata, comparing
+// the records to the expected decode generated by internal/gencorpus.
+func TestConformance(t *testing.T) {
+	logs, err := filepath.Glob(filepath.Join("testdata", "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) == 0 {
+		t.Fatal("empty corpus")
+	}
+	for _, path := range logs {
+		b, err := ioutil.ReadFile(strings.TrimSuffix(path, ".log") + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var expected interface{}
+		if err := json.Unmarshal(b, &expected); err != nil {
+			t.Fatal(err)
+		}
+		for name, read := range conformanceReaders {
+			t.Run(filepath.Base(path)+"/"+name, func(t *testing.T) {
+				actual := normalize(t, read(t, path))
+				if !reflect.DeepEqual(expected, actual) {
+					t.Errorf("got %v, want %v", actual, expected)
+				}
+			})
+		}
+	}
+}
+
+// normalize converts records to their generic JSON form, which is how the
+// expected decode is stored.
+func normalize(t *testing.T, records []Record) interface{} {
+	if records == nil {
+		records = []Record{}
+	}
+	b, err := json.Marshal(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func readAll(t *testing.T, reader *Reader) []Record {
+	var records []Record
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+}
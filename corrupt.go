@@ -0,0 +1,30 @@
+package tsv
+
+// maxControlBytes is the number of control characters other than NUL a line
+// may hold before RejectBinary treats it as corrupt. Zeek escapes control
+// characters, so they only appear in logs through corruption.
+const maxControlBytes = 4
+
+// RejectBinary configures the reader to skip data lines holding a NUL byte
+// or more than a few other control characters, as left by disk errors or
+// partial overwrites. Read returns a CorruptLineError for each such line,
+// after which reading may continue with the next line.
+func (r *Reader) RejectBinary(b bool) *Reader {
+	r.rejectBinary = b
+	return r
+}
+
+// isBinary reports whether line holds binary garbage.
+func (r *Reader) isBinary(line []byte) bool {
+	n := 0
+	for _, c := range line {
+		switch {
+		case c == 0:
+			return true
+		case c == r.parser.Delimiter || c == '\r' || c == '\n':
+		case c < 0x20 || c == 0x7f:
+			n++
+		}
+	}
+	return n > maxControlBytes
+}
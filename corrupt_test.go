@@ -0,0 +1,41 @@
+package tsv
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+func TestRejectBinary(t *testing.T) {
+	f, err := os.Open("testdata/corrupt/nul.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var records []Record
+	var corrupt []CorruptLineError
+	reader := NewReader(f).RejectBinary(true)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if e, ok := err.(CorruptLineError); ok {
+			corrupt = append(corrupt, e)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	// The NUL run overwrites the end of the second record and the start of
+	// the third, merging them into a single corrupt line.
+	if len(records) != 2 || records[1]["uid"] != "CtPZjS20MLrsMUOJi2" {
+		t.Errorf("expected the first and last records, got %v", records)
+	}
+	if len(corrupt) != 1 || corrupt[0].Offset != 587 || corrupt[0].Length != 265 {
+		t.Errorf("unexpected corrupt lines %v", corrupt)
+	}
+}
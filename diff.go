@@ -0,0 +1,112 @@
+package tsv
+
+// FieldDiffKind is the kind of change found by CompareHeaders.
+type FieldDiffKind int
+
+// Enum of field changes.
+const (
+	FieldAdded FieldDiffKind = iota
+	FieldRemoved
+	FieldRetyped
+	FieldMoved
+)
+
+// FieldDiff is a change to a single field between two headers. Indices
+// are -1 for fields absent from one of the headers.
+type FieldDiff struct {
+	Kind     FieldDiffKind
+	Field    string
+	OldIndex int
+	NewIndex int
+	OldType  FieldType
+	NewType  FieldType
+}
+
+// CompareHeaders returns the fields added, removed, retyped or moved
+// between headers a and b. The fields common to both headers that are
+// reported as moved are the fewest whose removal leaves the others in the
+// same order in both, so fields shifted only by additions, removals or
+// other moves are not reported. Removed, retyped and
+// moved fields are listed in the order of a, followed by added fields in
+// the order of b.
+func CompareHeaders(a, b *Header) []FieldDiff {
+	var diffs []FieldDiff
+	var commonA, commonB []string
+	for _, field := range a.Fields {
+		if _, ok := b.FieldIndex(field); ok {
+			commonA = append(commonA, field)
+		}
+	}
+	for _, field := range b.Fields {
+		if _, ok := a.FieldIndex(field); ok {
+			commonB = append(commonB, field)
+		}
+	}
+	stable := longestCommonSubsequence(commonA, commonB)
+
+	for i, field := range a.Fields {
+		oldType := fieldType(a, i)
+		j, ok := b.FieldIndex(field)
+		if !ok {
+			diffs = append(diffs, FieldDiff{FieldRemoved, field, i, -1, oldType, FieldType{}})
+			continue
+		}
+		newType := fieldType(b, j)
+		if oldType != newType {
+			diffs = append(diffs, FieldDiff{FieldRetyped, field, i, j, oldType, newType})
+		}
+		if !stable[field] {
+			diffs = append(diffs, FieldDiff{FieldMoved, field, i, j, oldType, newType})
+		}
+	}
+	for j, field := range b.Fields {
+		if _, ok := a.FieldIndex(field); !ok {
+			diffs = append(diffs, FieldDiff{FieldAdded, field, -1, j, FieldType{}, fieldType(b, j)})
+		}
+	}
+	return diffs
+}
+
+// longestCommonSubsequence returns the fields of a longest sequence found
+// in the same order in both a and b.
+func longestCommonSubsequence(a, b []string) map[string]bool {
+	// lengths[i][j] is the length of the longest common subsequence of
+	// a[i:] and b[j:].
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			case lengths[i+1][j] >= lengths[i][j+1]:
+				lengths[i][j] = lengths[i+1][j]
+			default:
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+	fields := make(map[string]bool, lengths[0][0])
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			fields[a[i]] = true
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return fields
+}
+
+func fieldType(h *Header, idx int) FieldType {
+	if idx < len(h.Types) {
+		return h.Types[idx]
+	}
+	return FieldType{}
+}
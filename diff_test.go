@@ -0,0 +1,66 @@
+package tsv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareHeaders(t *testing.T) {
+	str := FieldType{dataType: String}
+	count := FieldType{dataType: Count}
+	header := func(fields []string, types ...FieldType) *Header {
+		return &Header{Fields: fields, Types: types}
+	}
+	var tests = []struct {
+		name  string
+		a, b  *Header
+		diffs []FieldDiff
+	}{
+		{
+			name: "equal",
+			a:    header([]string{"a", "b"}, str, count),
+			b:    header([]string{"a", "b"}, str, count),
+		},
+		{
+			name: "added and removed",
+			a:    header([]string{"a", "b", "c"}, str, count, str),
+			b:    header([]string{"a", "c", "d"}, str, str, count),
+			diffs: []FieldDiff{
+				{FieldRemoved, "b", 1, -1, count, FieldType{}},
+				{FieldAdded, "d", -1, 2, FieldType{}, count},
+			},
+		},
+		{
+			name: "retyped",
+			a:    header([]string{"a", "b"}, str, count),
+			b:    header([]string{"a", "b"}, str, str),
+			diffs: []FieldDiff{
+				{FieldRetyped, "b", 1, 1, count, str},
+			},
+		},
+		{
+			name: "reordered",
+			a:    header([]string{"a", "b", "c"}, str, count, str),
+			b:    header([]string{"b", "a", "c"}, count, str, str),
+			diffs: []FieldDiff{
+				{FieldMoved, "a", 0, 1, str, str},
+			},
+		},
+		{
+			name: "rotated",
+			a:    header([]string{"a", "b", "c", "d"}, str, count, str, count),
+			b:    header([]string{"b", "c", "d", "a"}, count, str, count, str),
+			diffs: []FieldDiff{
+				{FieldMoved, "a", 0, 3, str, str},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs := CompareHeaders(tt.a, tt.b)
+			if !reflect.DeepEqual(diffs, tt.diffs) {
+				t.Errorf("got %v, want %v", diffs, tt.diffs)
+			}
+		})
+	}
+}
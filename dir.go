@@ -0,0 +1,98 @@
+package tsv
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/fs"
+	"sync"
+)
+
+// ProcessDir calls fn concurrently, with up to workers calls at a time, with
+// a reader of each log in fsys matching glob. Gzip-compressed logs are
+// decompressed. The readers are *Readers, wrapped to fail with the context
+// error once ctx is done, after which no more logs are opened and ProcessDir
+// returns the context error. Otherwise the errors opening the logs and
+// returned by fn are returned as DirErrors.
+func ProcessDir(ctx context.Context, fsys fs.FS, glob string, workers int, fn func(path string, rr RecordReader) error) error {
+	paths, err := fs.Glob(fsys, glob)
+	if err != nil {
+		return err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	errs := make([]error, len(paths))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if ctx.Err() == nil {
+					errs[i] = processFile(ctx, fsys, paths[i], fn)
+				}
+			}
+		}()
+	}
+feed:
+	for i := range paths {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var dirErrs DirErrors
+	for i, err := range errs {
+		if err != nil {
+			dirErrs = append(dirErrs, FileError{Path: paths[i], Err: err})
+		}
+	}
+	if dirErrs != nil {
+		return dirErrs
+	}
+	return nil
+}
+
+func processFile(ctx context.Context, fsys fs.FS, path string, fn func(string, RecordReader) error) error {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buffered := bufio.NewReader(f)
+	reader := NewReaderFromBufio(buffered)
+	if magic, _ := buffered.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		zr, err := gzip.NewReader(buffered)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		reader = NewReader(zr)
+	}
+	return fn(path, &contextReader{ctx, reader})
+}
+
+// contextReader is a Reader failing once its context is done.
+type contextReader struct {
+	ctx context.Context
+	*Reader
+}
+
+func (r *contextReader) Read() (Record, error) {
+	if err := r.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.Reader.Read()
+}
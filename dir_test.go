@@ -0,0 +1,119 @@
+package tsv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// failFS fails to open the file named fail.
+type failFS struct {
+	fstest.MapFS
+	fail string
+}
+
+func (f failFS) Open(name string) (fs.File, error) {
+	if name == f.fail {
+		return nil, fs.ErrPermission
+	}
+	return f.MapFS.Open(name)
+}
+
+func logFS() fstest.MapFS {
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	w.Write([]byte(input))
+	w.Close()
+	return fstest.MapFS{
+		"logs/a.log":    {Data: []byte(input)},
+		"logs/b.log":    {Data: []byte(input)},
+		"logs/c.log.gz": {Data: compressed.Bytes()},
+		"logs/d.txt":    {Data: []byte("not a log")},
+	}
+}
+
+func TestProcessDir(t *testing.T) {
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	err := ProcessDir(context.Background(), logFS(), "logs/*.log*", 2, func(path string, rr RecordReader) error {
+		for {
+			_, err := rr.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			counts[path]++
+			mu.Unlock()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 3 || counts["logs/a.log"] != 3 || counts["logs/c.log.gz"] != 3 {
+		t.Errorf("unexpected record counts %v", counts)
+	}
+
+	t.Run("empty glob", func(t *testing.T) {
+		err := ProcessDir(context.Background(), logFS(), "none/*.log", 2, func(string, RecordReader) error {
+			t.Error("unexpected call")
+			return nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		failure := errors.New("failure")
+		fsys := failFS{logFS(), "logs/a.log"}
+		err := ProcessDir(context.Background(), fsys, "logs/*.log*", 2, func(path string, rr RecordReader) error {
+			if path == "logs/c.log.gz" {
+				return failure
+			}
+			return nil
+		})
+		var dirErrs DirErrors
+		if !errors.As(err, &dirErrs) || len(dirErrs) != 2 {
+			t.Fatalf("expected 2 file errors, got %v", err)
+		}
+		if dirErrs[0].Path != "logs/a.log" || !errors.Is(dirErrs[0], fs.ErrPermission) {
+			t.Errorf("unexpected error %v", dirErrs[0])
+		}
+		if dirErrs[1].Path != "logs/c.log.gz" || !errors.Is(dirErrs[1], failure) {
+			t.Errorf("unexpected error %v", dirErrs[1])
+		}
+	})
+
+	t.Run("cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var calls int
+		err := ProcessDir(ctx, logFS(), "logs/*.log*", 1, func(path string, rr RecordReader) error {
+			calls++
+			if _, err := rr.Read(); err != nil {
+				return err
+			}
+			cancel()
+			_, err := rr.Read()
+			if err != context.Canceled {
+				t.Errorf("expected %v mid-file, got %v", context.Canceled, err)
+			}
+			return err
+		})
+		if err != context.Canceled {
+			t.Errorf("expected %v, got %v", context.Canceled, err)
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 call, got %d", calls)
+		}
+	})
+}
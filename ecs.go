@@ -0,0 +1,82 @@
+package tsv
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ECSField is the Elastic Common Schema destination of a zeek field.
+type ECSField struct {
+	Name string `json:"name"`
+	// Type optionally reshapes the converted value: "date" renders a time
+	// as an RFC 3339 string and "duration" converts seconds to nanoseconds.
+	Type string `json:"type,omitempty"`
+}
+
+// ECSMappings maps log paths and zeek field names to ECS fields. It holds
+// mappings for the conn, dns, http, ssl and files logs and may be extended.
+var ECSMappings map[string]map[string]ECSField
+
+//go:embed ecs.json
+var builtinECSMappings []byte
+
+func init() {
+	if err := json.Unmarshal(builtinECSMappings, &ECSMappings); err != nil {
+		panic(err)
+	}
+}
+
+// WithECSMapping configures the reader to rename the fields found in
+// ECSMappings for the log's path, reshaping their values as needed.
+// Unmapped fields are subject to the key transform as usual.
+func (r *Reader) WithECSMapping() *Reader {
+	r.ecs = true
+	return r
+}
+
+// applyECSMapping renames the mapped header fields and records how their
+// values are to be reshaped.
+func (r *Reader) applyECSMapping(header *Header) {
+	fields := ECSMappings[header.Path]
+	r.ecsTypes = make([]string, len(header.Fields))
+	// Fields may be shared through a HeaderCache.
+	header.Fields = append([]string(nil), header.Fields...)
+	for i, name := range header.originalFields {
+		if field, ok := fields[name]; ok {
+			header.Fields[i] = field.Name
+			r.ecsTypes[i] = field.Type
+		}
+	}
+}
+
+// ECSValue reshapes a converted value as described by an ECSField Type.
+func ECSValue(typ string, v interface{}) (interface{}, error) {
+	if v == nil || typ == "" {
+		return v, nil
+	}
+	var f float64
+	switch v := v.(type) {
+	case float64:
+		f = v
+	case Number:
+		var err error
+		if f, err = v.Float64(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("cannot convert %T to ECS %s", v, typ)
+	}
+	switch typ {
+	case "date":
+		// zeek times carry microsecond precision.
+		sec, frac := math.Modf(f)
+		usec := math.Round(frac * 1e6)
+		return time.Unix(int64(sec), int64(usec)*1e3).UTC().Format(time.RFC3339Nano), nil
+	case "duration":
+		return int64(math.Round(f * 1e9)), nil
+	}
+	return nil, fmt.Errorf("unknown ECS field type: %s", typ)
+}
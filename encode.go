@@ -0,0 +1,332 @@
+package tsv
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Encode converts rec to a Row in header field order, reversing the value
+// conversions done when reading. Missing and nil values are encoded as the
+// unset sentinel, and empty strings and containers as the empty sentinel.
+// Text values are escaped as zeek does, so that they read back as written
+// with Unescape.
+func (h *Header) Encode(rec Record) (Row, error) {
+	row := make(Row, len(h.Fields))
+	for i, field := range h.Fields {
+		v := rec[field]
+		if v == nil {
+			row[i] = h.Unset
+			continue
+		}
+		if i >= len(h.Types) {
+			return nil, ErrTruncatedLine
+		}
+		b, err := h.encodeValue(h.Types[i], v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field, err)
+		}
+		row[i] = b
+	}
+	return row, nil
+}
+
+func (h *Header) encodeValue(fieldType FieldType, v interface{}) ([]byte, error) {
+	if !fieldType.container {
+		b, err := ValueEncoders[fieldType.dataType](v)
+		if err == nil && len(b) == 0 {
+			return h.Empty, nil
+		}
+		if err == nil && isText(fieldType.dataType) {
+			b = h.appendEscaped(nil, string(b), false)
+		}
+		return b, err
+	}
+	values, ok := v.([]interface{})
+	if !ok {
+		// Typed containers, as returned with WithTypedContainers.
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("cannot encode %T as a container", v)
+		}
+		values = make([]interface{}, rv.Len())
+		for i := range values {
+			values[i] = rv.Index(i).Interface()
+		}
+	}
+	if len(values) == 0 {
+		return h.Empty, nil
+	}
+	var b []byte
+	for i, v := range values {
+		if i > 0 {
+			b = append(b, h.SetSeparator...)
+		}
+		elem, err := ValueEncoders[fieldType.dataType](v)
+		if err != nil {
+			return nil, err
+		}
+		if isText(fieldType.dataType) {
+			b = h.appendEscaped(b, string(elem), true)
+			continue
+		}
+		b = append(b, elem...)
+	}
+	return b, nil
+}
+
+// EncodeRow converts rec to a Row in header field order as Encode does with
+// the default ValueEncoders, reusing dst if it is large enough. The values
+// are appended to a shared buffer rather than allocated one by one.
+func EncodeRow(h *Header, rec Record, dst Row) (Row, error) {
+	if cap(dst) >= len(h.Fields) {
+		dst = dst[:len(h.Fields)]
+	} else {
+		dst = make(Row, len(h.Fields))
+	}
+	// Values appended before the buffer grows remain valid in the old one.
+	buf := make([]byte, 0, 32*len(h.Fields))
+	for i, field := range h.Fields {
+		v := rec[field]
+		if v == nil {
+			dst[i] = h.Unset
+			continue
+		}
+		if i >= len(h.Types) {
+			return nil, ErrTruncatedLine
+		}
+		start := len(buf)
+		var err error
+		buf, err = h.appendValue(buf, h.Types[i], v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field, err)
+		}
+		if len(buf) == start {
+			dst[i] = h.Empty
+			continue
+		}
+		dst[i] = buf[start:len(buf):len(buf)]
+	}
+	return dst, nil
+}
+
+func (h *Header) appendValue(b []byte, fieldType FieldType, v interface{}) ([]byte, error) {
+	if !fieldType.container {
+		return h.appendScalar(b, fieldType.dataType, v, false)
+	}
+	values, ok := v.([]interface{})
+	if !ok {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("cannot encode %T as a container", v)
+		}
+		values = make([]interface{}, rv.Len())
+		for i := range values {
+			values[i] = rv.Index(i).Interface()
+		}
+	}
+	for i, v := range values {
+		if i > 0 {
+			b = append(b, h.SetSeparator...)
+		}
+		var err error
+		if b, err = h.appendScalar(b, fieldType.dataType, v, true); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// appendScalar appends the encoding of v, a value of dataType, to b. elem
+// tells whether v is a container element.
+func (h *Header) appendScalar(b []byte, dataType DataType, v interface{}, elem bool) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		if isText(dataType) {
+			return h.appendEscaped(b, v, elem), nil
+		}
+	case uint64:
+		if dataType == Count {
+			return strconv.AppendUint(b, v, 10), nil
+		}
+	case int64:
+		if dataType == Int {
+			return strconv.AppendInt(b, v, 10), nil
+		}
+	case uint16:
+		if dataType == Port {
+			return strconv.AppendUint(b, uint64(v), 10), nil
+		}
+	case float64:
+		switch dataType {
+		case Time, Interval:
+			return AppendZeekInterval(b, v), nil
+		case Double:
+			return AppendZeekDouble(b, v), nil
+		}
+	case bool:
+		if dataType == Bool {
+			if v {
+				return append(b, 'T'), nil
+			}
+			return append(b, 'F'), nil
+		}
+	}
+	encoded, err := defaultEncoders[dataType](v)
+	if err == nil && isText(dataType) {
+		return h.appendEscaped(b, string(encoded), elem), nil
+	}
+	return append(b, encoded...), err
+}
+
+// isText reports whether values of dataType are written as text, which may
+// need escaping.
+func isText(dataType DataType) bool {
+	switch dataType {
+	case String, Addr, Enum, Subnet:
+		return true
+	}
+	return false
+}
+
+// defaultEncoders are the default ValueEncoders.
+var defaultEncoders [11]func(v interface{}) ([]byte, error)
+
+// ValueEncoders maps DataTypes to encoder functions, the inverse of
+// ValueConverters.
+var ValueEncoders [11]func(v interface{}) ([]byte, error)
+
+func init() {
+	ValueEncoders[String] = FromString
+	ValueEncoders[Time] = FromTime
+	ValueEncoders[Addr] = FromString
+	ValueEncoders[Port] = FromUint16
+	ValueEncoders[Int] = FromInt64
+	ValueEncoders[Double] = FromDouble
+	ValueEncoders[Count] = FromUint64
+	ValueEncoders[Interval] = FromInterval
+	ValueEncoders[Bool] = FromBool
+	ValueEncoders[Enum] = FromString
+	ValueEncoders[Subnet] = FromString
+	defaultEncoders = ValueEncoders
+}
+
+func invalidValue(v interface{}, typ string) error {
+	return fmt.Errorf("cannot encode %T as %s", v, typ)
+}
+
+// FromString encoder encodes a string or an EnumValue.
+func FromString(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v), nil
+	case EnumValue:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	}
+	return nil, invalidValue(v, "string")
+}
+
+// FromUint16 encoder encodes a uint16.
+func FromUint16(v interface{}) ([]byte, error) {
+	if i, ok := v.(uint16); ok {
+		return strconv.AppendUint(nil, uint64(i), 10), nil
+	}
+	return nil, invalidValue(v, "uint16")
+}
+
+// FromInt64 encoder encodes an int64.
+func FromInt64(v interface{}) ([]byte, error) {
+	if i, ok := v.(int64); ok {
+		return strconv.AppendInt(nil, i, 10), nil
+	}
+	return nil, invalidValue(v, "int64")
+}
+
+// FromUint64 encoder encodes a uint64.
+func FromUint64(v interface{}) ([]byte, error) {
+	if i, ok := v.(uint64); ok {
+		return strconv.AppendUint(nil, i, 10), nil
+	}
+	return nil, invalidValue(v, "uint64")
+}
+
+// FromFloat64 encoder encodes a float64 or a Number.
+func FromFloat64(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case float64:
+		return strconv.AppendFloat(nil, v, 'f', -1, 64), nil
+	case Number:
+		return []byte(v), nil
+	}
+	return nil, invalidValue(v, "float64")
+}
+
+// FromTime encoder encodes a time.Time, float64 or Number as seconds since
+// the Unix epoch, as FromInterval does.
+func FromTime(v interface{}) ([]byte, error) {
+	if t, ok := v.(time.Time); ok {
+		return []byte(fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/1e3)), nil
+	}
+	return FromInterval(v)
+}
+
+// FromInterval encoder encodes a float64 with six decimal places, as zeek
+// writes times and intervals, or a Number as is.
+func FromInterval(v interface{}) ([]byte, error) {
+	if f, ok := v.(float64); ok {
+		return AppendZeekInterval(nil, f), nil
+	}
+	return FromFloat64(v)
+}
+
+// FromDouble encoder encodes a float64 as zeek writes doubles, or a Number
+// as is.
+func FromDouble(v interface{}) ([]byte, error) {
+	if f, ok := v.(float64); ok {
+		return AppendZeekDouble(nil, f), nil
+	}
+	return FromFloat64(v)
+}
+
+// AppendZeekInterval appends f formatted with six decimal places, as zeek
+// writes times and intervals.
+func AppendZeekInterval(b []byte, f float64) []byte {
+	if f == 0 {
+		f = 0 // no negative zero
+	}
+	return strconv.AppendFloat(b, f, 'f', 6, 64)
+}
+
+// AppendZeekDouble appends f formatted as zeek writes doubles: with up to
+// six decimal places and trailing zeros removed, keeping ".0" for integral
+// values, or in exponent form beyond the int32 range.
+func AppendZeekDouble(b []byte, f float64) []byte {
+	if f == 0 {
+		f = 0 // no negative zero
+	}
+	if math.Abs(f) > math.MaxInt32 {
+		return strconv.AppendFloat(b, f, 'e', 6, 64)
+	}
+	// The decimal point stops trimming before any bytes preceding f.
+	b = bytes.TrimRight(strconv.AppendFloat(b, f, 'f', 6, 64), "0")
+	if b[len(b)-1] == '.' {
+		b = append(b, '0')
+	}
+	return b
+}
+
+// FromBool encoder encodes a bool as T or F.
+func FromBool(v interface{}) ([]byte, error) {
+	if b, ok := v.(bool); ok {
+		if b {
+			return []byte("T"), nil
+		}
+		return []byte("F"), nil
+	}
+	return nil, invalidValue(v, "bool")
+}
@@ -0,0 +1,298 @@
+package tsv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncode(t *testing.T) {
+	reader := NewReader(strings.NewReader(input))
+	records := collect(reader)
+	header := reader.Header()
+	lines := strings.Split(input[header.Length:], "\n")
+	// The reader maps empty values to nil, so the third record encodes as
+	// unset values.
+	for i, record := range records[:2] {
+		row, err := header.Encode(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		line := string(bytes.Join(row, []byte{header.Separator}))
+		// Intervals are written with six decimal places.
+		if want := strings.Replace(lines[i], "\t1,23.45", "\t1.000000,23.450000", 1); line != want {
+			t.Errorf("got %q, want %q", line, want)
+		}
+	}
+
+	row, err := header.Encode(Record{"ts": 1.5, "domains": []interface{}{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(row[0]) != "1.500000" || string(row[1]) != "-" || string(row[9]) != "(empty)" {
+		t.Errorf("unexpected row %q", row)
+	}
+
+	if _, err := header.Encode(Record{"bytes": "1001"}); err == nil {
+		t.Error("expected error encoding a string as a count")
+	}
+}
+
+// TestEncodeCorpus checks that encoding the records of the conformance
+// corpus reproduces the original rows.
+func TestEncodeCorpus(t *testing.T) {
+	logs, err := filepath.Glob(filepath.Join("testdata", "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range logs {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader := NewBytesReader(b)
+			for {
+				record, err := reader.Read()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				header := reader.Header()
+				row, err := header.Encode(record)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i, v := range reader.parser.Current() {
+					// The reader maps empty values to nil.
+					if bytes.Equal(v, header.Empty) {
+						v = header.Unset
+					}
+					if i < len(row) && !bytes.Equal(row[i], v) {
+						t.Errorf("%s: got %q, want %q", header.Fields[i], row[i], v)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestValueEncoders(t *testing.T) {
+	var tests = []struct {
+		dataType DataType
+		in       interface{}
+		out      string
+	}{
+		{String, "a b", "a b"},
+		{Time, 1546304400.000001, "1546304400.000001"},
+		{Time, time.Unix(1546304400, 1000), "1546304400.000001"},
+		{Addr, "::1", "::1"},
+		{Port, uint16(443), "443"},
+		{Int, int64(-10), "-10"},
+		{Double, 0.5, "0.5"},
+		{Double, 2.0, "2.0"},
+		{Double, -0.25, "-0.25"},
+		{Double, 0.125, "0.125"},
+		{Double, 1e10, "1.000000e+10"},
+		{Interval, 0.0, "0.000000"},
+		{Interval, -1.5, "-1.500000"},
+		{Interval, 86400.0, "86400.000000"},
+		{Double, Number("5"), "5"},
+		{Count, uint64(18446744073709551615), "18446744073709551615"},
+		{Interval, 3.755453, "3.755453"},
+		{Bool, true, "T"},
+		{Bool, false, "F"},
+		{Enum, "udp", "udp"},
+		{Subnet, "10.0.0.0/8", "10.0.0.0/8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.out, func(t *testing.T) {
+			b, err := ValueEncoders[tt.dataType](tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.out {
+				t.Errorf("got %q, want %q", b, tt.out)
+			}
+			if _, ok := tt.in.(time.Time); ok {
+				return
+			}
+			v, err := ValueConverters[tt.dataType](b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n, ok := tt.in.(Number); ok {
+				tt.in, _ = n.Float64()
+			}
+			if v != tt.in {
+				t.Errorf("round trip: got %v (%T), want %v (%T)", v, v, tt.in, tt.in)
+			}
+		})
+	}
+
+	for dataType, encoder := range ValueEncoders {
+		if _, err := encoder(struct{}{}); err == nil {
+			t.Errorf("expected error encoding struct{} as data type %d", dataType)
+		}
+	}
+}
+
+// randomValue returns a random value of dataType that survives a round trip
+// through the text format, read with Unescape. Strings include bytes that
+// must be escaped and values equal to the sentinels.
+func randomValue(rng *rand.Rand, dataType DataType) interface{} {
+	const letters = "abcdefghijklmnopqrstuvwxyz.-_:/\t\n\r\\,\x00\xff"
+	specials := []string{"-", "(empty)", ",", "\\x2d", "a\tb", "a,b", "\\"}
+	switch dataType {
+	case String, Enum:
+		if rng.Intn(8) == 0 {
+			return specials[rng.Intn(len(specials))]
+		}
+		b := make([]byte, 1+rng.Intn(12))
+		for i := range b {
+			b[i] = letters[rng.Intn(len(letters))]
+		}
+		return string(b)
+	case Addr:
+		return fmt.Sprintf("%d.%d.%d.%d", rng.Intn(256), rng.Intn(256), rng.Intn(256), rng.Intn(256))
+	case Subnet:
+		return fmt.Sprintf("10.%d.0.0/16", rng.Intn(256))
+	case Port:
+		return uint16(rng.Intn(1 << 16))
+	case Int:
+		return rng.Int63() - rng.Int63()
+	case Count:
+		return rng.Uint64()
+	case Time, Interval:
+		return float64(rng.Int63n(2e15)) / 1e6
+	case Double:
+		return float64(rng.Int63n(1e9)-5e8) / 64
+	case Bool:
+		return rng.Intn(2) == 0
+	}
+	panic("unknown data type")
+}
+
+func TestEncodeRowRoundTrip(t *testing.T) {
+	names := []string{"string", "time", "addr", "port", "int", "double", "count", "interval", "bool", "enum", "subnet"}
+	header := &Header{Separator: '\t', Unset: DefaultUnset, Empty: DefaultEmpty, SetSeparator: DefaultSetSeparator}
+	for _, name := range names {
+		for _, typeName := range []string{name, "vector[" + name + "]"} {
+			fieldType, err := ParseFieldType(typeName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			header.Fields = append(header.Fields, strings.NewReplacer("[", "_", "]", "").Replace(typeName))
+			header.Types = append(header.Types, fieldType)
+			header.typeNames = append(header.typeNames, typeName)
+		}
+	}
+	text := "#separator \\x09\n#set_separator\t,\n#empty_field\t(empty)\n#unset_field\t-\n" +
+		"#fields\t" + strings.Join(header.Fields, "\t") + "\n" +
+		"#types\t" + strings.Join(header.typeNames, "\t") + "\n"
+
+	rng := rand.New(rand.NewSource(1))
+	var records []Record
+	var lines bytes.Buffer
+	var row Row
+	for n := 0; n < 200; n++ {
+		record := make(Record)
+		for i, field := range header.Fields {
+			dataType := header.Types[i].dataType
+			switch {
+			case rng.Intn(10) == 0:
+				record[field] = nil
+			case header.Types[i].container:
+				values := make([]interface{}, 1+rng.Intn(4))
+				for j := range values {
+					values[j] = randomValue(rng, dataType)
+				}
+				record[field] = values
+			default:
+				record[field] = randomValue(rng, dataType)
+			}
+		}
+		var err error
+		if row, err = EncodeRow(header, record, row); err != nil {
+			t.Fatal(err)
+		}
+		lines.Write(bytes.Join(row, []byte("\t")))
+		lines.WriteByte('\n')
+		records = append(records, record)
+	}
+
+	reader := NewReader(strings.NewReader(text + lines.String())).Unescape(true)
+	for i, want := range records {
+		got, err := reader.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if eq, diffs := RecordsEqual(want, got); !eq {
+			t.Fatalf("record %d: %v", i, diffs)
+		}
+	}
+	if _, err := reader.Read(); err != io.EOF {
+		t.Errorf("expected %v, got %v", io.EOF, err)
+	}
+}
+
+func TestEncodeRowMatchesEncode(t *testing.T) {
+	reader := NewReader(strings.NewReader(input))
+	for _, record := range collect(reader) {
+		want, err := reader.Header().Encode(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := EncodeRow(reader.Header(), record, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+}
+
+func TestEncodeEscapes(t *testing.T) {
+	reader := NewReader(strings.NewReader(input))
+	record, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := reader.Header()
+	record["uid"] = "a\tb\\x41\n"
+	record["proto"] = "-"
+	record["domains"] = []interface{}{"a,b", "(empty)", "-"}
+	encoders := map[string]func() (Row, error){
+		"Encode":    func() (Row, error) { return header.Encode(record) },
+		"EncodeRow": func() (Row, error) { return EncodeRow(header, record, nil) },
+	}
+	for name, encode := range encoders {
+		t.Run(name, func(t *testing.T) {
+			row, err := encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(row[1]) != `a\x09b\x5cx41\x0a` {
+				t.Errorf("expected an escaped uid, got %q", row[1])
+			}
+			line := string(header.Raw()) + string(bytes.Join(row, []byte("\t"))) + "\n"
+			got, err := NewReader(strings.NewReader(line)).Unescape(true).Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if eq, diffs := RecordsEqual(record, got); !eq {
+				t.Errorf("round trip: %v", diffs)
+			}
+		})
+	}
+}
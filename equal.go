@@ -0,0 +1,198 @@
+package tsv
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Diff is a field whose values differ between two records. A and B are nil
+// for a field missing from the respective record.
+type Diff struct {
+	Field string
+	A     interface{}
+	B     interface{}
+}
+
+// CompareOption configures RecordsEqual.
+type CompareOption func(*compareConfig)
+
+type compareConfig struct {
+	tolerance  float64
+	nilMissing bool
+	unordered  bool
+	ignore     map[string]bool
+}
+
+// FloatTolerance makes RecordsEqual treat numbers as equal when they differ
+// by at most tolerance.
+func FloatTolerance(tolerance float64) CompareOption {
+	return func(c *compareConfig) {
+		c.tolerance = tolerance
+	}
+}
+
+// NilEqualsMissing makes RecordsEqual treat a nil value as equal to a
+// missing field.
+func NilEqualsMissing() CompareOption {
+	return func(c *compareConfig) {
+		c.nilMissing = true
+	}
+}
+
+// UnorderedContainers makes RecordsEqual compare containers as multisets,
+// ignoring the order of their elements.
+func UnorderedContainers() CompareOption {
+	return func(c *compareConfig) {
+		c.unordered = true
+	}
+}
+
+// IgnoreFields makes RecordsEqual skip the named fields.
+func IgnoreFields(fields ...string) CompareOption {
+	return func(c *compareConfig) {
+		for _, field := range fields {
+			c.ignore[field] = true
+		}
+	}
+}
+
+// RecordsEqual reports whether records a and b are equal, along with the
+// differing fields sorted by name. Numbers of different types, including
+// Numbers, compare equal when their values are, and times compare equal
+// when they denote the same instant. It is meant for tests of code
+// producing or consuming records.
+func RecordsEqual(a, b Record, opts ...CompareOption) (bool, []Diff) {
+	c := compareConfig{ignore: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	var diffs []Diff
+	for field, va := range a {
+		if c.ignore[field] {
+			continue
+		}
+		vb, ok := b[field]
+		if !ok && c.nilMissing && va == nil {
+			continue
+		}
+		if !ok || !c.valueEqual(va, vb) {
+			diffs = append(diffs, Diff{field, va, vb})
+		}
+	}
+	for field, vb := range b {
+		if _, ok := a[field]; ok || c.ignore[field] {
+			continue
+		}
+		if !c.nilMissing || vb != nil {
+			diffs = append(diffs, Diff{field, nil, vb})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Field < diffs[j].Field
+	})
+	return len(diffs) == 0, diffs
+}
+
+func (c *compareConfig) valueEqual(a, b interface{}) bool {
+	if ea, ok := a.([]interface{}); ok {
+		eb, ok := b.([]interface{})
+		return ok && c.containerEqual(ea, eb)
+	}
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		return ok && ta.Equal(tb)
+	}
+	if ba, ok := a.([]byte); ok {
+		bb, ok := b.([]byte)
+		return ok && bytes.Equal(ba, bb)
+	}
+	if na, ok := number(a); ok {
+		nb, ok := number(b)
+		return ok && numberEqual(na, nb, c.tolerance)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func (c *compareConfig) containerEqual(a, b []interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	if !c.unordered {
+		for i := range a {
+			if !c.valueEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	matched := make([]bool, len(b))
+	for _, va := range a {
+		found := false
+		for j, vb := range b {
+			if !matched[j] && c.valueEqual(va, vb) {
+				matched[j], found = true, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// number returns v as an int64, uint64 or float64 if it is numeric.
+func number(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case Number:
+		f, err := strconv.ParseFloat(string(v), 64)
+		return f, err == nil
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), true
+	}
+	return nil, false
+}
+
+func numberEqual(a, b interface{}, tolerance float64) bool {
+	switch a := a.(type) {
+	case int64:
+		switch b := b.(type) {
+		case int64:
+			return a == b
+		case uint64:
+			return a >= 0 && uint64(a) == b
+		}
+	case uint64:
+		switch b := b.(type) {
+		case int64:
+			return b >= 0 && uint64(b) == a
+		case uint64:
+			return a == b
+		}
+	}
+	return math.Abs(toFloat(a)-toFloat(b)) <= tolerance
+}
+
+func toFloat(v interface{}) float64 {
+	switch v := v.(type) {
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	}
+	return v.(float64)
+}
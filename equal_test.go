@@ -0,0 +1,40 @@
+package tsv
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecordsEqual(t *testing.T) {
+	ts := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		name  string
+		a, b  Record
+		opts  []CompareOption
+		diffs []Diff
+	}{
+		{"numeric types", Record{"n": uint64(1), "d": Number("1.5")}, Record{"n": 1, "d": 1.5}, nil, nil},
+		{"negative int", Record{"n": -1}, Record{"n": uint64(1)}, nil, []Diff{{"n", -1, uint64(1)}}},
+		{"float", Record{"d": 1.5}, Record{"d": 1.5000001}, nil, []Diff{{"d", 1.5, 1.5000001}}},
+		{"float tolerance", Record{"d": 1.5}, Record{"d": 1.5000001}, []CompareOption{FloatTolerance(1e-6)}, nil},
+		{"time", Record{"ts": ts}, Record{"ts": ts.In(time.FixedZone("", 3600))}, nil, nil},
+		{"nil and missing", Record{"a": nil}, Record{"b": nil}, nil, []Diff{{"a", nil, nil}, {"b", nil, nil}}},
+		{"nil equals missing", Record{"a": nil}, Record{"b": nil}, []CompareOption{NilEqualsMissing()}, nil},
+		{"container order", Record{"c": []interface{}{"a", "b"}}, Record{"c": []interface{}{"b", "a"}}, nil,
+			[]Diff{{"c", []interface{}{"a", "b"}, []interface{}{"b", "a"}}}},
+		{"unordered containers", Record{"c": []interface{}{"a", "b", "a"}}, Record{"c": []interface{}{"b", "a", "a"}},
+			[]CompareOption{UnorderedContainers()}, nil},
+		{"unordered multiset", Record{"c": []interface{}{"a", "a"}}, Record{"c": []interface{}{"a", "b"}},
+			[]CompareOption{UnorderedContainers()}, []Diff{{"c", []interface{}{"a", "a"}, []interface{}{"a", "b"}}}},
+		{"ignore fields", Record{"ts": 1.0, "uid": "C1"}, Record{"ts": 2.0, "uid": "C1"}, []CompareOption{IgnoreFields("ts")}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			equal, diffs := RecordsEqual(tt.a, tt.b, tt.opts...)
+			if equal != (len(tt.diffs) == 0) || !reflect.DeepEqual(diffs, tt.diffs) {
+				t.Errorf("expected %v, got %v (%v)", tt.diffs, diffs, equal)
+			}
+		})
+	}
+}
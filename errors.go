@@ -3,10 +3,18 @@ package tsv
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 var ErrTruncatedLine = errors.New("truncated line")
 var ErrInvalidSeparator = errors.New("invalid separator")
+var ErrNotSeekable = errors.New("input is not seekable")
+var ErrRecordLimit = errors.New("record limit reached")
+var ErrConvert = errors.New("cannot convert value")
+var ErrJSONFormatDetected = errors.New("input is a JSON log: read it as JSON, or configure zeek to write TSV logs")
+var ErrCompressedInput = errors.New("input is gzip-compressed: decompress it before reading")
+var ErrBudgetExceeded = errors.New("memory budget exceeded")
+var ErrLineTooLong = errors.New("line too long")
 
 type ErrorInvalidFieldType struct {
 	TypeName string
@@ -15,3 +23,61 @@ type ErrorInvalidFieldType struct {
 func (e ErrorInvalidFieldType) Error() string {
 	return fmt.Sprintf("unknown field type: %s", e.TypeName)
 }
+
+type ErrorUnknownField struct {
+	Field string
+}
+
+func (e ErrorUnknownField) Error() string {
+	return fmt.Sprintf("unknown field: %s", e.Field)
+}
+
+type ErrorDuplicateField struct {
+	Field string
+}
+
+func (e ErrorDuplicateField) Error() string {
+	return fmt.Sprintf("duplicate field: %s", e.Field)
+}
+
+type CorruptLineError struct {
+	Offset uint64
+	Length int
+}
+
+func (e CorruptLineError) Error() string {
+	return fmt.Sprintf("corrupt line at offset %d (%d bytes)", e.Offset, e.Length)
+}
+
+type ErrColumnCount struct {
+	Expected int
+	Got      int
+	Row      []byte
+}
+
+func (e ErrColumnCount) Error() string {
+	return fmt.Sprintf("expected %d columns, got %d: %q", e.Expected, e.Got, e.Row)
+}
+
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e FileError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e FileError) Unwrap() error {
+	return e.Err
+}
+
+type DirErrors []FileError
+
+func (e DirErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
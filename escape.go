@@ -0,0 +1,99 @@
+package tsv
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Unescape configures the reader to decode the \xNN escape sequences zeek
+// writes for separators and non-printable bytes inside values. Container
+// elements are split before decoding, so escaped set separators stay
+// within their element.
+func (r *Reader) Unescape(b bool) *Reader {
+	r.unescape = b
+	return r
+}
+
+// unescape decodes \xNN escape sequences in b. It returns b itself if it
+// contains none.
+func unescape(b []byte) []byte {
+	i := bytes.Index(b, []byte(`\x`))
+	if i < 0 {
+		return b
+	}
+	out := append(make([]byte, 0, len(b)), b[:i]...)
+	for ; i < len(b); i++ {
+		if isEscape(b, i) {
+			out = append(out, unhex(b[i+2])<<4|unhex(b[i+3]))
+			i += 3
+			continue
+		}
+		out = append(out, b[i])
+	}
+	return out
+}
+
+// appendEscaped appends v to b, escaping as \xNN the bytes that would not
+// read back as written: the separator, control bytes and backslashes, and
+// within a container element the set separator. A value equal to the unset
+// or empty sentinel has its first byte escaped.
+func (h *Header) appendEscaped(b []byte, v string, elem bool) []byte {
+	sep := h.Separator
+	if sep == 0 {
+		sep = '\t'
+	}
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case c == sep || c == '\\' || c < 0x20 || c == 0x7f,
+			i == 0 && (len(h.Unset) > 0 && v == string(h.Unset) || len(h.Empty) > 0 && v == string(h.Empty)),
+			elem && len(h.SetSeparator) > 0 && strings.HasPrefix(v[i:], string(h.SetSeparator)):
+			b = append(b, '\\', 'x', hexDigits[c>>4], hexDigits[c&0xf])
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
+
+const hexDigits = "0123456789abcdef"
+
+// splitEscaped slices b into the subslices separated by sep, treating
+// \xNN escape sequences as opaque.
+func splitEscaped(b, sep []byte) [][]byte {
+	if len(sep) == 0 {
+		return [][]byte{b}
+	}
+	var parts [][]byte
+	start := 0
+	for i := 0; i < len(b); i++ {
+		if isEscape(b, i) {
+			i += 3
+			continue
+		}
+		if bytes.HasPrefix(b[i:], sep) {
+			parts = append(parts, b[start:i])
+			start = i + len(sep)
+			i = start - 1
+		}
+	}
+	return append(parts, b[start:])
+}
+
+func isEscape(b []byte, i int) bool {
+	return i+3 < len(b) && b[i] == '\\' && b[i+1] == 'x' && isHex(b[i+2]) && isHex(b[i+3])
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
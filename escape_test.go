@@ -0,0 +1,81 @@
+package tsv
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var escapedInput = `#separator \x09
+#set_separator	,
+#empty_field	(empty)
+#unset_field	-
+#path	dns
+#fields	query	qtype_name	answers	TTLs
+#types	string	string	vector[string]	vector[interval]
+example.com	TXT	TXT 37 v=spf1 ip4:192.0.2.0/24\x2cip4:198.51.100.0/24 -all,TXT 12 hello\x09world	300.000000,300.000000
+\x2d	A	\x2d,(empty)	60.000000
+#close	2019-01-01-00-00-01
+`
+
+func TestUnescape(t *testing.T) {
+	reader := NewReader(strings.NewReader(escapedInput)).Unescape(true)
+	records := collect(reader)
+	expectedRecords := []Record{
+		{
+			"query":      "example.com",
+			"qtype_name": "TXT",
+			"answers": []interface{}{
+				"TXT 37 v=spf1 ip4:192.0.2.0/24,ip4:198.51.100.0/24 -all",
+				"TXT 12 hello\tworld",
+			},
+			"TTLs": []interface{}{float64(300), float64(300)},
+		},
+		{
+			"query":      "-",
+			"qtype_name": "A",
+			"answers":    []interface{}{"-", "(empty)"},
+			"TTLs":       []interface{}{float64(60)},
+		},
+	}
+	if !reflect.DeepEqual(records, expectedRecords) {
+		t.Errorf("got %v, want %v", records, expectedRecords)
+	}
+
+	reader = NewReader(strings.NewReader(escapedInput))
+	record, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answers := record["answers"].([]interface{}); !strings.Contains(answers[0].(string), `\x2c`) {
+		t.Errorf("expected escapes to be left alone by default, got %q", answers)
+	}
+}
+
+func TestSplitEscaped(t *testing.T) {
+	var tests = []struct {
+		in, sep string
+		out     []string
+	}{
+		{`a,b`, ",", []string{"a", "b"}},
+		{`a\x2cb,c`, ",", []string{`a\x2cb`, "c"}},
+		{`,`, ",", []string{"", ""}},
+		{`a\x2`, ",", []string{`a\x2`}},
+		{`1\x2c2`, "2", []string{`1\x2c`, ""}},
+		{`a::b`, "::", []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var parts []string
+			for _, p := range splitEscaped([]byte(tt.in), []byte(tt.sep)) {
+				parts = append(parts, string(p))
+			}
+			if !reflect.DeepEqual(parts, tt.out) {
+				t.Errorf("got %q, want %q", parts, tt.out)
+			}
+			if tt.out[0] == `a\x2` && string(unescape([]byte(tt.in))) != tt.in {
+				t.Error("expected incomplete escape to be left alone")
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package tsv
+
+import "bytes"
+
+// RecordFlags describe the values of a record.
+type RecordFlags uint8
+
+// Flags of a record.
+const (
+	HadUnset              RecordFlags = 1 << iota // a field was unset
+	HadEmpty                                      // a field was empty
+	HadConversionFallback                         // a lenient policy replaced or dropped a value
+)
+
+// FlagCounts counts the records read by flag.
+type FlagCounts struct {
+	Unset              uint64
+	Empty              uint64
+	ConversionFallback uint64
+	Populated          uint64 // records with no unset or empty field
+}
+
+// LastRecordFlags returns the flags of the record most recently read or
+// peeked. Fallbacks are replacements of unset and empty values by
+// WithNumericZeroDefault and the handling of unset and empty container
+// elements by WithUnsetElements.
+func (r *Reader) LastRecordFlags() RecordFlags {
+	return r.flags
+}
+
+// FlagCounts returns the number of records read so far with each flag.
+func (r *Reader) FlagCounts() FlagCounts {
+	return r.flagCounts
+}
+
+// markSentinel flags the record as holding the unset or empty sentinel b.
+func (r *Reader) markSentinel(b []byte) {
+	if bytes.Equal(b, r.header.Unset) {
+		r.flags |= HadUnset
+	} else {
+		r.flags |= HadEmpty
+	}
+}
+
+// countFlags adds the flags of the record just read to the counts.
+func (r *Reader) countFlags() {
+	c := &r.flagCounts
+	if r.flags&HadUnset != 0 {
+		c.Unset++
+	}
+	if r.flags&HadEmpty != 0 {
+		c.Empty++
+	}
+	if r.flags&HadConversionFallback != 0 {
+		c.ConversionFallback++
+	}
+	if r.flags&(HadUnset|HadEmpty) == 0 {
+		c.Populated++
+	}
+}
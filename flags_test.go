@@ -0,0 +1,46 @@
+package tsv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRecordFlags(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		reader   *Reader
+		expected []RecordFlags
+		counts   FlagCounts
+	}{
+		{
+			"default",
+			NewReader(strings.NewReader(input)).OmitEmpty(true),
+			[]RecordFlags{0, HadUnset, HadEmpty},
+			FlagCounts{Unset: 1, Empty: 1, Populated: 1},
+		},
+		{
+			"numeric zero",
+			NewReader(strings.NewReader(input)).WithNumericZeroDefault(true),
+			[]RecordFlags{0, HadUnset | HadConversionFallback, HadEmpty | HadConversionFallback},
+			FlagCounts{Unset: 1, Empty: 1, ConversionFallback: 2, Populated: 1},
+		},
+		{
+			"unset elements",
+			NewReader(strings.NewReader(strings.Replace(input, "a.com,b.com", "a.com,-", 1))).WithUnsetElements(UnsetElementsSkip),
+			[]RecordFlags{HadConversionFallback, HadUnset, HadEmpty},
+			FlagCounts{Unset: 1, Empty: 1, ConversionFallback: 1, Populated: 1},
+		},
+	} {
+		for i, expected := range tc.expected {
+			if _, err := tc.reader.Read(); err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			if flags := tc.reader.LastRecordFlags(); flags != expected {
+				t.Errorf("%s: record %d: expected flags %b, got %b", tc.name, i, expected, flags)
+			}
+		}
+		if counts := tc.reader.FlagCounts(); counts != tc.counts {
+			t.Errorf("%s: expected counts %+v, got %+v", tc.name, tc.counts, counts)
+		}
+	}
+}
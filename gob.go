@@ -0,0 +1,130 @@
+package tsv
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+func init() {
+	// Besides the basic types gob knows, the values of records may be times,
+	// as the close record holds, and durations made by transforms.
+	gob.Register(time.Time{})
+	gob.Register(time.Duration(0))
+	gob.Register(Number(""))
+	gob.Register(EnumValue(""))
+	gob.Register([]interface{}{})
+	gob.Register(json.RawMessage{})
+}
+
+// gobHeader is the encoding of a Header in a gob stream.
+type gobHeader struct {
+	Separator      byte
+	Fields         []string
+	OriginalFields []string
+	TypeNames      []string
+	Unset          []byte
+	Empty          []byte
+	SetSeparator   []byte
+	Path           string
+	Open           time.Time
+	OpenLayout     string
+}
+
+// WriteGob writes the header followed by all remaining records to w as a
+// stream of gob values, to be read back with NewGobReader.
+func (r *Reader) WriteGob(w io.Writer) error {
+	h := r.Header()
+	if h == nil {
+		return r.headerErr
+	}
+	enc := gob.NewEncoder(w)
+	gh := gobHeader{
+		Separator:      h.Separator,
+		Fields:         h.Fields,
+		OriginalFields: h.OriginalFields(),
+		Unset:          h.Unset,
+		Empty:          h.Empty,
+		SetSeparator:   h.SetSeparator,
+		Path:           h.Path,
+		Open:           h.Open,
+		OpenLayout:     h.OpenLayout,
+	}
+	for i := range h.Types {
+		gh.TypeNames = append(gh.TypeNames, fieldTypeName(h, i))
+	}
+	if err := enc.Encode(&gh); err != nil {
+		return err
+	}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+}
+
+// GobReader reads the records of a stream written by WriteGob.
+type GobReader struct {
+	dec    *gob.Decoder
+	header *Header
+	err    error
+}
+
+// NewGobReader creates a reader of the stream of gob values in r.
+func NewGobReader(r io.Reader) *GobReader {
+	return &GobReader{dec: gob.NewDecoder(r)}
+}
+
+// Header returns the header of the stream, reading it first if needed, or
+// nil if it cannot be read, in which case Read returns the error.
+func (r *GobReader) Header() *Header {
+	if r.header != nil || r.err != nil {
+		return r.header
+	}
+	var gh gobHeader
+	if r.err = r.dec.Decode(&gh); r.err != nil {
+		return nil
+	}
+	h := &Header{
+		Separator:      gh.Separator,
+		Fields:         gh.Fields,
+		Unset:          gh.Unset,
+		Empty:          gh.Empty,
+		SetSeparator:   gh.SetSeparator,
+		Path:           gh.Path,
+		Open:           gh.Open,
+		OpenLayout:     gh.OpenLayout,
+		originalFields: gh.OriginalFields,
+		typeNames:      gh.TypeNames,
+	}
+	for _, name := range gh.TypeNames {
+		var fieldType FieldType
+		if fieldType, r.err = readFieldType(name); r.err != nil {
+			return nil
+		}
+		h.Types = append(h.Types, fieldType)
+	}
+	h.index()
+	r.header = h
+	return h
+}
+
+// Read returns the next record, or io.EOF at the end of the stream.
+func (r *GobReader) Read() (Record, error) {
+	if r.Header() == nil {
+		return nil, r.err
+	}
+	var record Record
+	if err := r.dec.Decode(&record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
@@ -0,0 +1,54 @@
+package tsv
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGob(t *testing.T) {
+	for name, config := range map[string]func(*Reader) *Reader{
+		"default":          func(r *Reader) *Reader { return r },
+		"typed containers": func(r *Reader) *Reader { return r.WithTypedContainers(true).WithEnumType(true) },
+		"numeric text":     func(r *Reader) *Reader { return r.PreserveNumericText(true).OmitEmpty(true) },
+		"close record": func(r *Reader) *Reader {
+			return r.WithCloseRecord(true).WithRecordTransform(func(record Record) (Record, error) {
+				record["seen"], record["wait"] = time.Unix(1546304400, 0).UTC(), time.Second
+				return record, nil
+			})
+		},
+	} {
+		expected := collect(config(NewReader(strings.NewReader(input))))
+		source := config(NewReader(strings.NewReader(input)))
+		var buf bytes.Buffer
+		if err := source.WriteGob(&buf); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		reader := NewGobReader(&buf)
+		header := reader.Header()
+		if header == nil || !reflect.DeepEqual(header.Fields, source.Header().Fields) ||
+			!reflect.DeepEqual(header.Types, source.Header().Types) || !header.Open.Equal(source.Header().Open) {
+			t.Fatalf("%s: unexpected header %+v", name, header)
+		}
+		for i, want := range expected {
+			got, err := reader.Read()
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("%s: record %d: expected %#v, got %#v", name, i, want, got)
+			}
+		}
+		if _, err := reader.Read(); err != io.EOF {
+			t.Errorf("%s: expected %v, got %v", name, io.EOF, err)
+		}
+	}
+
+	if _, err := NewGobReader(strings.NewReader("junk")).Read(); err == nil {
+		t.Error("expected an error reading junk")
+	}
+}
@@ -0,0 +1,122 @@
+package tsv
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+	"sort"
+	"time"
+)
+
+// Markers written before each value hashed by HashRow and Record.Hash.
+const (
+	hashUnset byte = iota
+	hashEmpty
+	hashValue
+	hashContainer
+	hashSeparator = 0x1f
+)
+
+// HashRow writes the raw values of fields in row to h, or of all fields if
+// none are given. Each value is preceded by a marker distinguishing unset
+// and empty values from others and followed by a separator byte. Values are
+// hashed as written in the log, so the result does not depend on reader
+// options such as Unescape.
+func (h *Header) HashRow(w hash.Hash, row Row, fields ...string) error {
+	if len(fields) == 0 {
+		for i := range h.Fields {
+			h.hashValue(w, row, i)
+		}
+		return nil
+	}
+	for _, field := range fields {
+		i, ok := h.FieldIndex(field)
+		if !ok {
+			return ErrorUnknownField{field}
+		}
+		h.hashValue(w, row, i)
+	}
+	return nil
+}
+
+func (h *Header) hashValue(w hash.Hash, row Row, i int) {
+	var v []byte
+	if i < len(row) {
+		v = row[i]
+	}
+	switch {
+	case i >= len(row) || bytes.Equal(v, h.Unset):
+		w.Write([]byte{hashUnset, hashSeparator})
+	case bytes.Equal(v, h.Empty):
+		w.Write([]byte{hashEmpty, hashSeparator})
+	default:
+		w.Write([]byte{hashValue})
+		w.Write(v)
+		w.Write([]byte{hashSeparator})
+	}
+}
+
+// WithRecordHash configures the reader to store under key the hex encoded
+// SHA-256 digest of the raw values of fields, as computed by HashRow. The
+// digest is added before the record transform is applied.
+func (r *Reader) WithRecordHash(key string, fields ...string) *Reader {
+	r.hashKey = key
+	r.hashFields = fields
+	if r.hash == nil {
+		r.hash = sha256.New()
+	}
+	return r
+}
+
+// recordHash returns the digest of row configured by WithRecordHash.
+func (r *Reader) recordHash(row Row) (string, error) {
+	r.hash.Reset()
+	if err := r.header.HashRow(r.hash, row, r.hashFields...); err != nil {
+		return "", err
+	}
+	r.sum = r.hash.Sum(r.sum[:0])
+	return hex.EncodeToString(r.sum), nil
+}
+
+// Hash returns the FNV-1a hash of the fields and values of r, in field
+// order so that equal records hash equal. Values are hashed in their string
+// form, with times in UTC, and nil values are distinguished from empty ones.
+// As in HashRow, field names and values are followed by a separator byte.
+func (r Record) Hash() uint64 {
+	fields := make([]string, 0, len(r))
+	for field := range r {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	h := fnv.New64a()
+	for _, field := range fields {
+		io.WriteString(h, field)
+		h.Write([]byte{hashSeparator})
+		writeHashValue(h, r[field])
+	}
+	return h.Sum64()
+}
+
+func writeHashValue(w hash.Hash, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		w.Write([]byte{hashUnset})
+	case []interface{}:
+		w.Write([]byte{hashContainer})
+		for _, e := range v {
+			writeHashValue(w, e)
+		}
+	case time.Time:
+		w.Write([]byte{hashValue})
+		io.WriteString(w, v.UTC().Format(time.RFC3339Nano))
+	default:
+		w.Write([]byte{hashValue})
+		fmt.Fprint(w, v)
+	}
+	w.Write([]byte{hashSeparator})
+}
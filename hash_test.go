@@ -0,0 +1,84 @@
+package tsv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordHash(t *testing.T) {
+	sum := sha256.Sum256([]byte("\x02CCb2Mx28qOMGD3hxab\x1f\x021.1.1.1\x1f"))
+	want := hex.EncodeToString(sum[:])
+	in := strings.Replace(input, "a.com,b.com", `a\x2ccom,b.com`, 1)
+
+	var all []string
+	for name, newReader := range map[string]func() *Reader{
+		"stream":   func() *Reader { return NewReader(strings.NewReader(in)) },
+		"bytes":    func() *Reader { return NewBytesReader([]byte(in)) },
+		"unescape": func() *Reader { return NewReader(strings.NewReader(in)).Unescape(true) },
+	} {
+		records := collect(newReader().WithRecordHash("_hash", "uid", "id.orig_h"))
+		if len(records) != 3 {
+			t.Fatalf("%s: expected 3 records, got %d", name, len(records))
+		}
+		if records[0]["_hash"] != want {
+			t.Errorf("%s: expected %s, got %v", name, want, records[0]["_hash"])
+		}
+
+		var digests []string
+		for _, record := range collect(newReader().WithRecordHash("_hash")) {
+			digests = append(digests, record["_hash"].(string))
+		}
+		// The second and third records differ only in unset and empty values.
+		if len(digests) != 3 || digests[1] == digests[2] {
+			t.Errorf("%s: expected unset and empty values to hash differently", name)
+		}
+		if all != nil && !reflect.DeepEqual(digests, all) {
+			t.Errorf("%s: expected digests %v, got %v", name, all, digests)
+		}
+		all = digests
+	}
+
+	_, err := NewReader(strings.NewReader(input)).WithRecordHash("_hash", "missing").Read()
+	if err != (ErrorUnknownField{"missing"}) {
+		t.Errorf("expected unknown field error, got %v", err)
+	}
+}
+
+func TestHash(t *testing.T) {
+	ts := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := Record{"ts": ts, "uid": "C1", "n": uint64(1), "domains": []interface{}{"a.com"}, "note": nil}
+	b := Record{"note": nil, "domains": []interface{}{"a.com"}, "n": uint64(1), "uid": "C1", "ts": ts.Local()}
+	if a.Hash() != b.Hash() {
+		t.Error("expected equal records to hash equal")
+	}
+	for _, other := range []Record{
+		{"ts": ts, "uid": "C1", "n": uint64(1), "domains": []interface{}{"a.com"}, "note": ""},
+		{"ts": ts, "uid": "C1", "n": uint64(1), "domains": []interface{}{"a.com"}},
+		{"ts": ts, "uid": "C1", "n": uint64(2), "domains": []interface{}{"a.com"}, "note": nil},
+		{"ts": ts, "uid": "C1", "n": uint64(1), "domains": []interface{}{}, "note": nil},
+	} {
+		if a.Hash() == other.Hash() {
+			t.Errorf("expected %v and %v to hash differently", a, other)
+		}
+	}
+	for _, pair := range [][2]Record{
+		{{"a": "x", "yz": "1"}, {"a": "xy", "z": "1"}},
+		{{"a": []interface{}{"x"}, "b": "y"}, {"a": []interface{}{"x", "b"}}},
+	} {
+		if pair[0].Hash() == pair[1].Hash() {
+			t.Errorf("expected %v and %v to hash differently", pair[0], pair[1])
+		}
+	}
+
+	records := collect(NewBytesReader([]byte(input)))
+	again := collect(NewReader(strings.NewReader(input)))
+	for i := range records {
+		if records[i].Hash() != again[i].Hash() {
+			t.Errorf("record %d: expected hashes from both readers to match", i)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package tsv
+
+import (
+	"bufio"
+	"io"
+)
+
+// Default sentinels of the interchange format.
+var (
+	DefaultUnset        = []byte("-")
+	DefaultEmpty        = []byte("(empty)")
+	DefaultSetSeparator = []byte(",")
+)
+
+// NewInterchangeReader creates a new reader for plain TSV files whose first
+// row holds the field names and second row the zeek types, with no '#'
+// directives. Data rows are encoded as in zeek logs, using the default
+// sentinels unless configured otherwise with WithSentinels. The last row
+// may lack its newline, and a file of only the two header rows holds no
+// records.
+func NewInterchangeReader(r io.Reader) *Reader {
+	parser := NewParser(r)
+	parser.eofLine = true
+	return &Reader{parser: parser, interchange: true}
+}
+
+// WithSentinels configures the unset and empty sentinels and the set
+// separator of an interchange reader.
+func (r *Reader) WithSentinels(unset, empty, setSeparator []byte) *Reader {
+	r.sentinels = &Header{Unset: unset, Empty: empty, SetSeparator: setSeparator}
+	return r
+}
+
+// ParseFieldType parses a zeek type name such as "vector[string]".
+func ParseFieldType(s string) (FieldType, error) {
+	return readFieldType(s)
+}
+
+func (r *Reader) readInterchangeHeader() (*Header, error) {
+	header := Header{
+		Separator:    '\t',
+		Unset:        DefaultUnset,
+		Empty:        DefaultEmpty,
+		SetSeparator: DefaultSetSeparator,
+	}
+	if r.sentinels != nil {
+		header.Unset = r.sentinels.Unset
+		header.Empty = r.sentinels.Empty
+		header.SetSeparator = r.sentinels.SetSeparator
+	}
+	for i := 0; i < 2; i++ {
+		row, err := r.parser.Read()
+		if err != nil {
+			return nil, err
+		}
+		r.parser.ResetRow()
+		if err := r.retain(len(r.parser.line), "header"); err != nil {
+			return nil, err
+		}
+		if !r.discardRaw {
+			header.raw = append(header.raw, r.parser.line...)
+		}
+		if i == 0 {
+			err = r.addFields(&header, row)
+		} else {
+			err = addTypes(&header, row)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Leave the first data row current, as readHeader does, if any.
+	if _, err := r.parser.Read(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	header.Length = r.parser.start
+	if r.ecs {
+		r.applyECSMapping(&header)
+	}
+	header.index()
+	return &header, nil
+}
+
+// InterchangeWriter writes records in the format read by
+// NewInterchangeReader.
+type InterchangeWriter struct {
+	w       *bufio.Writer
+	header  *Header
+	started bool
+}
+
+// NewInterchangeWriter creates a writer of records described by header.
+// The header's sentinels are used to encode values.
+func NewInterchangeWriter(w io.Writer, header *Header) *InterchangeWriter {
+	return &InterchangeWriter{w: bufio.NewWriter(w), header: header}
+}
+
+// Write writes rec, preceded by the header rows if it is the first record.
+func (w *InterchangeWriter) Write(rec Record) error {
+	if !w.started {
+		if err := w.writeHeader(); err != nil {
+			return err
+		}
+	}
+	row, err := w.header.Encode(rec)
+	if err != nil {
+		return err
+	}
+	return w.writeRow(row)
+}
+
+// Flush writes the header rows if no record was written, and any buffered
+// data to the underlying writer.
+func (w *InterchangeWriter) Flush() error {
+	if !w.started {
+		if err := w.writeHeader(); err != nil {
+			return err
+		}
+	}
+	return w.w.Flush()
+}
+
+func (w *InterchangeWriter) writeHeader() error {
+	w.started = true
+	names := make(Row, len(w.header.Fields))
+	types := make(Row, len(w.header.Fields))
+	for i, field := range w.header.Fields {
+		names[i] = []byte(field)
+		types[i] = []byte(fieldTypeName(w.header, i))
+	}
+	if err := w.writeRow(names); err != nil {
+		return err
+	}
+	return w.writeRow(types)
+}
+
+func (w *InterchangeWriter) writeRow(row Row) error {
+	for i, v := range row {
+		if i > 0 {
+			w.w.WriteByte('\t')
+		}
+		w.w.Write(v)
+	}
+	return w.w.WriteByte('\n')
+}
+
+// fieldTypeName returns the zeek type name of the field at i.
+func fieldTypeName(h *Header, i int) string {
+	if i < len(h.typeNames) {
+		return h.typeNames[i]
+	}
+	if i >= len(h.Types) {
+		return ""
+	}
+	var name string
+	for n, dataType := range dataTypeLookup {
+		if dataType == h.Types[i].dataType {
+			name = n
+		}
+	}
+	if h.Types[i].container {
+		return "vector[" + name + "]"
+	}
+	return name
+}
@@ -0,0 +1,97 @@
+package tsv
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var interchangeInput = `ts	uid	domains	n
+time	string	set[string]	count
+1546304400.000001	CCb2Mx28qOMGD3hxab	a.com|b.com	-
+1546304400.000002	-	(none)	2
+`
+
+func TestInterchangeReader(t *testing.T) {
+	reader := NewInterchangeReader(strings.NewReader(interchangeInput)).
+		WithSentinels([]byte("-"), []byte("(none)"), []byte("|"))
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Record{
+		{"ts": 1546304400.000001, "uid": "CCb2Mx28qOMGD3hxab", "domains": []interface{}{"a.com", "b.com"}, "n": nil},
+		{"ts": 1546304400.000002, "uid": nil, "domains": nil, "n": uint64(2)},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("expected %d records, got %d", len(expected), len(records))
+	}
+	for i := range expected {
+		if ok, diffs := RecordsEqual(expected[i], records[i]); !ok {
+			t.Errorf("record %d: %v", i, diffs)
+		}
+	}
+	header := reader.Header()
+	if header.Length != uint64(strings.Index(interchangeInput, "1546304400")) {
+		t.Errorf("expected header length %d, got %d", strings.Index(interchangeInput, "1546304400"), header.Length)
+	}
+	if string(header.Raw())+interchangeInput[header.Length:] != interchangeInput {
+		t.Error("raw header does not reproduce the input")
+	}
+
+	var b bytes.Buffer
+	w := NewInterchangeWriter(&b, header)
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// Empty values are read as nil, so they are written back as unset.
+	want := strings.Replace(interchangeInput, "(none)", "-", 1)
+	if b.String() != want {
+		t.Errorf("expected %q, got %q", want, b.String())
+	}
+}
+
+func TestInterchangeUnterminated(t *testing.T) {
+	in := strings.TrimSuffix(interchangeInput, "\n")
+	reader := NewInterchangeReader(strings.NewReader(in)).
+		WithSentinels([]byte("-"), []byte("(none)"), []byte("|"))
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1]["n"] != uint64(2) {
+		t.Errorf("expected the unterminated last record, got %v", records)
+	}
+}
+
+func TestInterchangeEmpty(t *testing.T) {
+	header := NewInterchangeReader(strings.NewReader(interchangeInput)).Header()
+	var b bytes.Buffer
+	if err := NewInterchangeWriter(&b, header).Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range []string{b.String(), strings.TrimSuffix(b.String(), "\n")} {
+		reader := NewInterchangeReader(strings.NewReader(in))
+		records, err := reader.ReadAll()
+		if err != nil || len(records) != 0 {
+			t.Errorf("%q: expected no records, got %v (%v)", in, records, err)
+		}
+		if reader.Header() == nil || len(reader.Header().Fields) != 4 || reader.Header().Length != uint64(len(in)) {
+			t.Errorf("%q: expected the header, got %v", in, reader.Header())
+		}
+	}
+}
+
+func TestParseFieldType(t *testing.T) {
+	if ft, err := ParseFieldType("vector[interval]"); err != nil || ft != (FieldType{Interval, true}) {
+		t.Errorf("got %v, %v", ft, err)
+	}
+	if _, err := ParseFieldType("table"); err != (ErrorInvalidFieldType{"table"}) {
+		t.Errorf("expected invalid field type, got %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build go1.20
+// +build go1.20
+
+package tsv
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestEnumInterning(t *testing.T) {
+	data := func(v interface{}) uintptr {
+		s := v.(string)
+		return uintptr(unsafe.Pointer(unsafe.StringData(s)))
+	}
+	row := input[strings.Index(input, "\n1546304400")+1:]
+	row = row[:strings.IndexByte(row, '\n')+1]
+	in := strings.Replace(input, row, row+row, 1)
+
+	records := collect(NewReader(strings.NewReader(in)).WithEnumInterning(true))
+	if records[0]["proto"] != "udp" || data(records[0]["proto"]) != data(records[1]["proto"]) {
+		t.Errorf("expected interned enum values, got %v and %v", records[0]["proto"], records[1]["proto"])
+	}
+	records = collect(NewReader(strings.NewReader(in)))
+	if data(records[0]["proto"]) == data(records[1]["proto"]) {
+		t.Error("expected enum values not to be interned by default")
+	}
+}
@@ -0,0 +1,64 @@
+// Command gencorpus writes the expected decode of each log in the
+// conformance corpus, as produced by the eager reader.
+//
+// Usage:
+//
+//	go run ./internal/gencorpus [dir]
+//
+// For every dir/*.log it writes dir/*.json, overwriting existing files.
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	zeek "github.com/0xcc-labs/zeek-tsv"
+)
+
+func main() {
+	dir := "testdata"
+	if len(os.Args) > 1 {
+		dir = os.Args[1]
+	}
+	logs, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, path := range logs {
+		if err := generate(path); err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+	}
+}
+
+func generate(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	records := []zeek.Record{}
+	reader := zeek.NewReader(f)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+	b, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	out := strings.TrimSuffix(path, ".log") + ".json"
+	return ioutil.WriteFile(out, append(b, '\n'), 0644)
+}
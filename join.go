@@ -0,0 +1,170 @@
+package tsv
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// RecordReader reads Records, as Reader does.
+type RecordReader interface {
+	Read() (Record, error)
+}
+
+// DefaultJoinWindow is the default number of records buffered from each
+// secondary log by a UIDJoiner.
+const DefaultJoinWindow = 1000
+
+// UIDJoiner merges the records of secondary logs into the records of a
+// primary log sharing the same value of a key field, typically joining
+// http, dns or ssl logs to a conn log on uid.
+type UIDJoiner struct {
+	primary     RecordReader
+	secondaries []*joinSecondary
+	key         string
+	window      int
+	seq         uint64
+	evicted     uint64
+}
+
+type joinSecondary struct {
+	path    string
+	reader  RecordReader
+	done    bool
+	queue   []*joinEntry
+	byKey   map[string][]*joinEntry
+	pending int
+}
+
+type joinEntry struct {
+	seq     uint64
+	key     string
+	record  Record
+	matched bool
+}
+
+// NewUIDJoiner creates a joiner of the records of secondaries into those of
+// primary on the field key. Secondaries are keyed by a path prefixed to the
+// names of their fields in merged records, as in "http.host".
+func NewUIDJoiner(primary RecordReader, secondaries map[string]RecordReader, key string) *UIDJoiner {
+	j := &UIDJoiner{primary: primary, key: key, window: DefaultJoinWindow}
+	for path, reader := range secondaries {
+		j.secondaries = append(j.secondaries, &joinSecondary{
+			path:   path,
+			reader: reader,
+			byKey:  make(map[string][]*joinEntry),
+		})
+	}
+	sort.Slice(j.secondaries, func(a, b int) bool {
+		return j.secondaries[a].path < j.secondaries[b].path
+	})
+	return j
+}
+
+// WithWindow configures the number of records read ahead from each
+// secondary log, which bounds how far out of order the logs may be. A
+// buffered secondary record left unmatched after window primary records is
+// evicted.
+func (j *UIDJoiner) WithWindow(n int) *UIDJoiner {
+	j.window = n
+	return j
+}
+
+// Evicted returns the number of secondary records evicted unmatched.
+func (j *UIDJoiner) Evicted() uint64 {
+	return j.evicted
+}
+
+// Read returns the next primary record, merged with the first buffered
+// record of each secondary with the same key. Primary records without a
+// match are returned as they are.
+func (j *UIDJoiner) Read() (Record, error) {
+	record, err := j.primary.Read()
+	if err != nil {
+		return nil, err
+	}
+	j.seq++
+	for _, s := range j.secondaries {
+		j.evict(s)
+		if err := j.fill(s); err != nil {
+			return nil, err
+		}
+	}
+	v, ok := record[j.key]
+	if !ok || v == nil {
+		return record, nil
+	}
+	key := joinKey(v)
+	for _, s := range j.secondaries {
+		entries := s.byKey[key]
+		if len(entries) == 0 {
+			continue
+		}
+		entry := entries[0]
+		entry.matched = true
+		s.pending--
+		if len(entries) == 1 {
+			delete(s.byKey, key)
+		} else {
+			s.byKey[key] = entries[1:]
+		}
+		for field, v := range entry.record {
+			if field != j.key {
+				record[s.path+"."+field] = v
+			}
+		}
+	}
+	return record, nil
+}
+
+// evict drops the records of s left unmatched for a full window.
+func (j *UIDJoiner) evict(s *joinSecondary) {
+	for len(s.queue) > 0 && s.queue[0].seq+uint64(j.window) < j.seq {
+		entry := s.queue[0]
+		s.queue = s.queue[1:]
+		if entry.matched {
+			continue
+		}
+		j.evicted++
+		s.pending--
+		entries := s.byKey[entry.key]
+		if len(entries) == 1 {
+			delete(s.byKey, entry.key)
+		} else {
+			s.byKey[entry.key] = entries[1:]
+		}
+	}
+	for len(s.queue) > 0 && s.queue[0].matched {
+		s.queue = s.queue[1:]
+	}
+}
+
+// fill reads records of s until window of them are pending.
+func (j *UIDJoiner) fill(s *joinSecondary) error {
+	for !s.done && s.pending < j.window {
+		record, err := s.reader.Read()
+		if err == io.EOF {
+			s.done = true
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.path, err)
+		}
+		v, ok := record[j.key]
+		if !ok || v == nil {
+			continue
+		}
+		entry := &joinEntry{seq: j.seq, key: joinKey(v), record: record}
+		s.queue = append(s.queue, entry)
+		s.byKey[entry.key] = append(s.byKey[entry.key], entry)
+		s.pending++
+	}
+	return nil
+}
+
+func joinKey(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,82 @@
+package tsv
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+type sliceReader []Record
+
+func (r *sliceReader) Read() (Record, error) {
+	if len(*r) == 0 {
+		return nil, io.EOF
+	}
+	record := (*r)[0]
+	*r = (*r)[1:]
+	return record, nil
+}
+
+func openLog(t *testing.T, path string) *Reader {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return NewReader(f)
+}
+
+func TestUIDJoiner(t *testing.T) {
+	joiner := NewUIDJoiner(openLog(t, "testdata/conn.log"), map[string]RecordReader{
+		"dns":  openLog(t, "testdata/dns.log"),
+		"http": openLog(t, "testdata/http.log"),
+		"ssl":  openLog(t, "testdata/ssl.log"),
+	}, "uid")
+	var records []Record
+	for {
+		record, err := joiner.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 4 {
+		t.Fatalf("expected 4 records, got %d", len(records))
+	}
+	var tests = []struct {
+		field string
+		value interface{}
+	}{
+		{"dns.query", nil},
+		{"ssl.ts", 1546300801.360002},
+		{"dns.query", "www.example.com"},
+		{"http.ts", 1546300802.501102},
+	}
+	for i, tt := range tests {
+		if v := records[i][tt.field]; v != tt.value {
+			t.Errorf("record %d: expected %s %v, got %v", i, tt.field, tt.value, v)
+		}
+		if _, ok := records[i]["http.uid"]; ok {
+			t.Errorf("record %d: expected secondary key to be dropped", i)
+		}
+	}
+
+	primary := sliceReader{{"uid": "A"}, {"uid": "B"}, {"uid": "C"}, {"uid": "D"}}
+	secondary := sliceReader{{"uid": "X", "n": 0}, {"uid": "A", "n": 1}, {"uid": "B", "n": 2}}
+	joiner = NewUIDJoiner(&primary, map[string]RecordReader{"s": &secondary}, "uid").WithWindow(2)
+	for _, want := range []interface{}{1, 2, nil, nil} {
+		record, err := joiner.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record["s.n"] != want {
+			t.Errorf("%v: expected s.n %v, got %v", record["uid"], want, record["s.n"])
+		}
+	}
+	if joiner.Evicted() != 1 {
+		t.Errorf("expected 1 evicted record, got %d", joiner.Evicted())
+	}
+}
@@ -0,0 +1,32 @@
+package tsv
+
+// Logger receives reports about malformed input the reader tolerated.
+// kv holds alternating attribute names and values, as accepted by
+// log/slog; every report carries the line number, counted from the start
+// of input or the last seek, and the byte offset of the offending line.
+type Logger func(level, msg string, kv ...interface{})
+
+// maxSnippet is the maximum length of raw values included in reports.
+const maxSnippet = 64
+
+// WithLogger configures the reader to report tolerated input to logger.
+func (r *Reader) WithLogger(logger Logger) *Reader {
+	r.logger = logger
+	return r
+}
+
+func (r *Reader) warn(msg string, kv ...interface{}) {
+	if r.logger == nil {
+		return
+	}
+	kv = append([]interface{}{"line", r.parser.lines, "offset", r.parser.start}, kv...)
+	r.logger("warn", msg, kv...)
+}
+
+// snippet returns b as a string, truncated to maxSnippet bytes.
+func snippet(b []byte) string {
+	if len(b) > maxSnippet {
+		return string(b[:maxSnippet]) + "..."
+	}
+	return string(b)
+}
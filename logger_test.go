@@ -0,0 +1,29 @@
+package tsv
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	in := strings.Replace(input, "#open\t2019-01-01-00-00-00", "#open\tyesterday\n#comment\tx", 1)
+	var warnings []string
+	logger := func(level, msg string, kv ...interface{}) {
+		warnings = append(warnings, fmt.Sprint(append([]interface{}{level, msg}, kv...)))
+	}
+	reader := NewReader(strings.NewReader(in)).WithLogger(logger)
+	if _, err := collectWithError(reader); err == nil {
+		t.Fatal("expected io.EOF")
+	}
+	expectedWarnings := []string{
+		fmt.Sprint([]interface{}{"warn", "unparseable time", "line", 6,
+			"offset", strings.Index(in, "#open"), "field", "#open", "value", "yesterday"}),
+		fmt.Sprint([]interface{}{"warn", "unknown header directive", "line", 7,
+			"offset", strings.Index(in, "#comment"), "directive", "#comment"}),
+	}
+	if !reflect.DeepEqual(warnings, expectedWarnings) {
+		t.Errorf("got %q, want %q", warnings, expectedWarnings)
+	}
+}
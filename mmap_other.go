@@ -0,0 +1,17 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package tsv
+
+import "io/ioutil"
+
+// OpenMmap reads the file at path into memory and returns a reader over
+// its bytes, along with a no-op close function. Memory mapping is not
+// supported on this platform.
+func OpenMmap(path string) (*Reader, func() error, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewBytesReader(b), func() error { return nil }, nil
+}
@@ -0,0 +1,33 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package tsv
+
+import (
+	"os"
+	"syscall"
+)
+
+// OpenMmap memory-maps the file at path and returns a reader over the
+// mapped bytes, along with a function that unmaps them. Raw values alias
+// the mapping and must not be used after it is closed.
+func OpenMmap(path string) (*Reader, func() error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Size() == 0 {
+		return NewBytesReader(nil), func() error { return nil }, nil
+	}
+	b, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, &os.PathError{Op: "mmap", Path: path, Err: err}
+	}
+	return NewBytesReader(b), func() error { return syscall.Munmap(b) }, nil
+}
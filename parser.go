@@ -12,61 +12,160 @@ type Row [][]byte
 // Parser reads Rows from byte-separated input.
 type Parser struct {
 	Delimiter byte
-	reader    *bufio.Reader
+	source    LineSource
 	row       Row
 	n         int
+	cols      int
+	project   bool
+	maxCol    int
+	wanted    []bool
+	line      []byte
+	offset    uint64
+	start     uint64
+	lines     uint64
+	maxLine   int
+	eofLine   bool // whether a final data line may lack its newline
 }
 
 // NewParser returns a new Parser that reads from r.
 func NewParser(r io.Reader) *Parser {
-	reader := bufio.NewReader(r)
+	return NewSourceParser(&readerSource{r: r, reader: bufio.NewReader(r)})
+}
+
+// NewBytesParser returns a new Parser that reads from b without copying.
+func NewBytesParser(b []byte) *Parser {
+	return NewSourceParser(&bytesSource{data: b})
+}
 
+// NewSourceParser returns a new Parser that reads lines from src.
+func NewSourceParser(src LineSource) *Parser {
 	return &Parser{
 		Delimiter: '\t',
-		reader:    reader,
+		source:    src,
 	}
 }
 
-// Read reads one Row from r.
+// Read reads one Row from r. A final line starting with '#' that lacks its
+// newline (typically a "#close ..." footer) is returned along with io.EOF;
+// other such lines are truncated, except in interchange files, which have
+// no footer.
+// Lines may end in CRLF, in header directives as in data; the '\r' is
+// stripped from the final column, the only one it can end.
 func (p *Parser) Read() (Row, error) {
-	line, err := p.reader.ReadBytes('\n')
+	line, err := p.readLine()
+	p.line = line
+	p.start = p.offset
+	p.offset += uint64(len(line))
+	p.lines++
 	if err != nil {
-		if err == io.EOF && len(line) != 0 && !bytes.HasPrefix(line, []byte("#")) {
+		if err != io.EOF || len(line) == 0 {
+			return nil, err
+		}
+		if p.eofLine {
+			return p.split(line), nil
+		}
+		if !bytes.HasPrefix(line, []byte("#")) {
 			return nil, ErrTruncatedLine
 		}
-		// Remaining possibilities are:
-		// - io.EOF with truncation on a line starting with '#' (typically a "#close ..." footer)
-		// - io.EOF with no line truncation
-		// - some other (non-EOF) error
-		return nil, err
+		return p.split(line), io.EOF
 	}
+	return p.split(line), nil
+}
 
+// split splits line into the columns of a Row.
+func (p *Parser) split(line []byte) Row {
 	if p.n == 0 {
 		// count columns
 		p.n = bytes.Count(line, []byte{p.Delimiter}) + 1
 		p.row = make(Row, p.n)
 	}
 
+	if p.project {
+		return p.splitProjected(line)
+	}
+
 	var n, start int
+	stop := len(line)
 	for i, c := range line {
 		if c == p.Delimiter {
+			if n == len(p.row)-1 {
+				// Columns past those of the first row are dropped.
+				stop = i
+				break
+			}
 			p.row[n] = line[start:i]
 			start = i + 1
 			n++
 		}
 	}
+	if stop < len(line) {
+		p.row[n] = line[start:stop]
+		p.cols = n + 1
+		return p.row
+	}
 
 	// Handle final column, including stripping (\r)\n from it.
 	end := len(line) - 1
 	if line[end] == '\n' {
 		end--
 	}
-	if line[end] == '\r' {
+	if end >= 0 && line[end] == '\r' {
 		end--
 	}
 	p.row[n] = line[start : end+1]
+	p.cols = n + 1
+
+	return p.row
+}
+
+// splitProjected splits line as configured by SetProjection.
+func (p *Parser) splitProjected(line []byte) Row {
+	var n, start int
+	for i, c := range line {
+		if c == p.Delimiter {
+			p.setColumn(n, line[start:i])
+			// Columns past those of the first row are dropped.
+			if n == p.maxCol || n == len(p.row)-1 {
+				p.cols = n + 1
+				return p.row
+			}
+			start = i + 1
+			n++
+		}
+	}
 
-	return p.row, nil
+	end := len(line) - 1
+	if line[end] == '\n' {
+		end--
+	}
+	if end >= 0 && line[end] == '\r' {
+		end--
+	}
+	p.setColumn(n, line[start:end+1])
+	p.cols = n + 1
+
+	return p.row
+}
+
+func (p *Parser) setColumn(n int, b []byte) {
+	if p.wanted == nil || n < len(p.wanted) && p.wanted[n] {
+		p.row[n] = b
+	} else {
+		p.row[n] = nil
+	}
+}
+
+// SetProjection restricts splitting to the columns up to maxCol for which
+// wanted is true, or all of them if wanted is nil. Other columns of the
+// returned Rows are nil, and the line past column maxCol is not scanned.
+// A negative maxCol removes the projection.
+func (p *Parser) SetProjection(maxCol int, wanted []bool) {
+	p.project = maxCol >= 0
+	p.maxCol = maxCol
+	p.wanted = wanted
+	for i := range p.row {
+		p.row[i] = nil
+	}
 }
 
 // Current returns the most recently read Row.
@@ -74,6 +173,88 @@ func (p *Parser) Current() Row {
 	return p.row
 }
 
+// Line returns the raw line of the most recently read Row, including its
+// line terminator.
+func (p *Parser) Line() []byte {
+	return p.line
+}
+
+// ColumnSpan returns the byte range of column idx of the most recently read
+// Row within its Line. Spans are not available with a projection.
+func (p *Parser) ColumnSpan(idx int) (start, end int, ok bool) {
+	if idx < 0 || idx >= p.cols || p.project {
+		return 0, 0, false
+	}
+	for _, col := range p.row[:idx] {
+		start += len(col) + 1
+	}
+	return start, start + len(p.row[idx]), true
+}
+
+// Offset returns the number of input bytes consumed so far.
+func (p *Parser) Offset() uint64 {
+	return p.offset
+}
+
+// readLine returns the next line, including its terminating newline. It does
+// not advance the offset.
+func (p *Parser) readLine() ([]byte, error) {
+	line, err := p.source.ReadLine()
+	if p.maxLine > 0 && len(line) > p.maxLine {
+		return nil, ErrLineTooLong
+	}
+	return line, err
+}
+
+// skip consumes the next n bytes of input, which must end a line, without
+// splitting them.
+func (p *Parser) skip(n uint64) error {
+	end := p.offset + n
+	for p.offset < end {
+		line, err := p.readLine()
+		p.offset += uint64(len(line))
+		p.lines++
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetMaxLineSize makes Read return ErrLineTooLong for lines longer than n
+// bytes, including their newline, without buffering more of them than that.
+// Zero means no limit. Reading cannot continue after ErrLineTooLong.
+func (p *Parser) SetMaxLineSize(n int) {
+	p.maxLine = n
+	if limiter, ok := p.source.(lineLimiter); ok {
+		limiter.setMaxLine(n)
+	}
+}
+
+// peek returns up to n bytes of the next line without consuming them, or
+// nil if the source cannot look ahead.
+func (p *Parser) peek(n int) []byte {
+	if peeker, ok := p.source.(linePeeker); ok {
+		return peeker.peek(n)
+	}
+	return nil
+}
+
+// seek repositions the source so that the next line starts at offset.
+func (p *Parser) seek(offset uint64) error {
+	seeker, ok := p.source.(LineSeeker)
+	if !ok {
+		return ErrNotSeekable
+	}
+	if err := seeker.Seek(offset); err != nil {
+		return err
+	}
+	p.offset = offset
+	p.start = offset
+	p.lines = 0
+	return nil
+}
+
 // ResetRow clears the row metadata.
 func (p *Parser) ResetRow() {
 	p.n = 0
@@ -1,29 +1,175 @@
 package tsv
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
 	"io"
+	"math"
+	"reflect"
 	"strconv"
 	"strings"
+	"time"
 	"unsafe"
 )
 
 // Record is a tsv file record.
 type Record map[string]interface{}
 
+// EnumValue is a value of a zeek enum column, as returned by readers
+// configured with WithEnumType. It encodes to JSON as a string.
+type EnumValue string
+
+// Number is the text of a zeek numeric value.
+type Number string
+
+// Float64 returns the number as a float64.
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// String returns the text of the number.
+func (n Number) String() string {
+	return string(n)
+}
+
+// MarshalJSON implements json.Marshaler, emitting the number verbatim if it
+// is valid JSON, and null for NaN and infinities, which JSON cannot
+// represent. Other numbers are reformatted.
+func (n Number) MarshalJSON() ([]byte, error) {
+	if isJSONNumber(string(n)) {
+		return []byte(n), nil
+	}
+	f, err := n.Float64()
+	switch {
+	case math.IsNaN(f) || math.IsInf(f, 0):
+		return []byte("null"), nil
+	case err != nil:
+		return nil, err
+	}
+	return strconv.AppendFloat(nil, f, 'g', -1, 64), nil
+}
+
+// isJSONNumber reports whether s is a number in the JSON grammar.
+func isJSONNumber(s string) bool {
+	digits := func(i int) int {
+		for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+			i++
+		}
+		return i
+	}
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	switch {
+	case i < len(s) && s[i] == '0':
+		i++
+	case i < len(s) && '1' <= s[i] && s[i] <= '9':
+		i = digits(i)
+	default:
+		return false
+	}
+	if i < len(s) && s[i] == '.' {
+		if j := digits(i + 1); j > i+1 {
+			i = j
+		} else {
+			return false
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if j := digits(i); j > i {
+			i = j
+		} else {
+			return false
+		}
+	}
+	return i == len(s)
+}
+
 // KeyTransform is a key transform function.
 type KeyTransform func(key string) string
 
+// RecordTransform is a record transform function. It may modify and return
+// the record, return a nil Record to drop it, or return an error.
+type RecordTransform func(record Record) (Record, error)
+
 // Reader is a zeek tsv file reader.
 type Reader struct {
-	parser       *Parser
-	header       *Header
-	keyTransform KeyTransform
-	omitEmpty    bool
+	parser          *Parser
+	header          *Header
+	headerErr       error
+	pendingRow      bool
+	keyTransform    KeyTransform
+	recordTransform RecordTransform
+	omitEmpty       bool
+	numericText     bool
+	numericZero     bool
+	unescape        bool
+	ecs             bool
+	ecsTypes        []string
+	timeLayouts     []string
+	logger          Logger
+	discardRaw      bool
+	closeRecord     bool
+	closeMarker     []byte
+	skipComments    bool
+	closed          bool
+	footerSeen      bool
+	truncated       bool
+	records         uint64
+	flags           RecordFlags
+	flagCounts      FlagCounts
+	progressTotal   uint64
+	progressNext    uint64
+	progressFn      func(read, total uint64)
+	maxRecords      uint64
+	hashKey         string
+	hashFields      []string
+	hash            hash.Hash
+	sum             []byte
+	interchange     bool
+	sentinels       *Header
+	peeked          bool
+	peek            Record
+	peekErr         error
+	peekStart       uint64
+	peekRecords     uint64
+	peekFlagCounts  FlagCounts
+	typedContainers bool
+	unsetElements   UnsetElements
+	rename          map[string]string
+	headerOnly      bool
+	rejectBinary    bool
+	columnCheck     bool
+	rawColumns      []string
+	fieldConverters map[string]func(b []byte) (interface{}, error)
+	fieldTypes      map[string]reflect.Type                 // types of fieldConverters values
+	columnConverter []func(b []byte) (interface{}, error)   // fieldConverters by column
+	converters      [11]func(b []byte) (interface{}, error) // overrides of ValueConverters
+	setSeparators   [11][]byte                              // overrides of the header set separator
+	enums           map[string]interface{}
+	enumType        bool
+	canonicalIPv4   bool
+	strictAddrs     bool
+	budget          int
+	headerCache     *HeaderCache
+	knownHeader     *Header
+	retained        int
+	tee             io.Reader // drained into the archive by Drain
 }
 
-// Header is a zeek tsv file header.
+// Header is a zeek tsv file header. Fields holds the canonical field names,
+// produced by the key transform or ECS mapping if any; they are the keys of
+// records and the names accepted and reported by all Header methods and
+// errors. OriginalFields returns the names as written in the log.
 type Header struct {
 	Separator    byte
 	Fields       []string
@@ -32,6 +178,26 @@ type Header struct {
 	Empty        []byte
 	SetSeparator []byte
 	Path         string
+	Length       uint64 // size of the header in bytes
+	Open         time.Time
+	OpenLayout   string    // layout Open was parsed with
+	Close        time.Time // set once the #close footer has been read
+
+	raw            []byte
+	originalFields []string
+	typeNames      []string
+	columns        []Column
+	fieldIndex     map[string]int
+}
+
+// Column describes a single log column.
+type Column struct {
+	Name         string       // field name after key transformation
+	OriginalName string       // field name as written in #fields
+	Index        int          // position within the row
+	Type         string       // zeek type, e.g. "vector[string]"
+	GoType       reflect.Type // type of the converted values, nil if unknown
+	Container    bool
 }
 
 // FieldType is a zeek field type.
@@ -73,21 +239,287 @@ var dataTypeLookup = map[string]DataType{
 	"subnet":   Subnet,
 }
 
-// ValueConverters maps DataTypes to converter functions.
+// ValueConverters maps DataTypes to converter functions. Replace them with
+// RegisterConverter, so that the type of the values they return is known.
 var ValueConverters [11]func(b []byte) (interface{}, error)
 
+// registered holds the converters set by RegisterConverter along with the
+// types of the values they return.
+var registered [11]struct {
+	convert func(b []byte) (interface{}, error)
+	typ     reflect.Type
+}
+
+// defaultTypes are the types of the values returned by DefaultConverters.
+var defaultTypes = [11]reflect.Type{
+	String:   reflect.TypeOf(""),
+	Time:     reflect.TypeOf(float64(0)),
+	Addr:     reflect.TypeOf(""),
+	Port:     reflect.TypeOf(uint16(0)),
+	Int:      reflect.TypeOf(int64(0)),
+	Double:   reflect.TypeOf(float64(0)),
+	Count:    reflect.TypeOf(uint64(0)),
+	Interval: reflect.TypeOf(float64(0)),
+	Bool:     reflect.TypeOf(false),
+	Enum:     reflect.TypeOf(""),
+	Subnet:   reflect.TypeOf(""),
+}
+
+// RegisterConverter sets the converter of dataType values in
+// ValueConverters, along with the type of the values it returns.
+func RegisterConverter(dataType DataType, typ reflect.Type, convert func(b []byte) (interface{}, error)) {
+	ValueConverters[dataType] = convert
+	registered[dataType].convert, registered[dataType].typ = convert, typ
+}
+
+// converterType returns the type of the values returned by convert, a
+// converter of dataType values, or nil if it is neither registered nor a
+// default converter.
+func converterType(dataType DataType, convert func(b []byte) (interface{}, error)) reflect.Type {
+	switch {
+	case sameFunc(convert, registered[dataType].convert):
+		return registered[dataType].typ
+	case isDefaultConverter(dataType, convert):
+		return defaultTypes[dataType]
+	}
+	return nil
+}
+
+// isDefaultConverter reports whether convert is the default converter of
+// dataType values.
+func isDefaultConverter(dataType DataType, convert func(b []byte) (interface{}, error)) bool {
+	return sameFunc(convert, DefaultConverters()[dataType])
+}
+
+func sameFunc(a, b func(b []byte) (interface{}, error)) bool {
+	return a != nil && b != nil && reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+// DefaultConverters returns a copy of the default ValueConverters.
+func DefaultConverters() [11]func(b []byte) (interface{}, error) {
+	return [11]func(b []byte) (interface{}, error){
+		String:   ToString,
+		Time:     ToFloat64,
+		Addr:     ToString,
+		Port:     ToUint16,
+		Int:      ToInt64,
+		Double:   ToFloat64,
+		Count:    ToUint64,
+		Interval: ToFloat64,
+		Bool:     ToBool,
+		Enum:     ToString,
+		Subnet:   ToString,
+	}
+}
+
 func init() {
-	ValueConverters[String] = ToString
-	ValueConverters[Time] = ToFloat64
-	ValueConverters[Addr] = ToString
-	ValueConverters[Port] = ToUint16
-	ValueConverters[Int] = ToInt64
-	ValueConverters[Double] = ToFloat64
-	ValueConverters[Count] = ToUint64
-	ValueConverters[Interval] = ToFloat64
-	ValueConverters[Bool] = ToBool
-	ValueConverters[Enum] = ToString
-	ValueConverters[Subnet] = ToString
+	for dataType, convert := range DefaultConverters() {
+		RegisterConverter(DataType(dataType), defaultTypes[dataType], convert)
+	}
+}
+
+// Raw returns the header exactly as read, or nil if the reader was
+// configured not to retain it.
+func (h *Header) Raw() []byte {
+	return h.raw
+}
+
+// OriginalFields returns the field names as written in the log, before any
+// key transform or ECS mapping.
+func (h *Header) OriginalFields() []string {
+	if h.originalFields == nil {
+		return h.Fields
+	}
+	return h.originalFields
+}
+
+// FieldIndex returns the position of field within a row.
+func (h *Header) FieldIndex(field string) (int, bool) {
+	if h.fieldIndex == nil {
+		for i := len(h.Fields) - 1; i >= 0; i-- {
+			if h.Fields[i] == field {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+	idx, ok := h.fieldIndex[field]
+	return idx, ok
+}
+
+// TypeOf returns the type of field.
+func (h *Header) TypeOf(field string) (FieldType, bool) {
+	idx, ok := h.FieldIndex(field)
+	if !ok || idx >= len(h.Types) {
+		return FieldType{}, false
+	}
+	return h.Types[idx], true
+}
+
+// IsContainer reports whether field holds a set or vector, and whether the
+// field was found.
+func (h *Header) IsContainer(field string) (bool, bool) {
+	t, ok := h.TypeOf(field)
+	return t.container, ok
+}
+
+// Columns returns a description of each column in the log.
+func (h *Header) Columns() []Column {
+	if h.columns != nil {
+		return h.columns
+	}
+	return h.makeColumns()
+}
+
+// makeColumns describes each column of h.
+func (h *Header) makeColumns() []Column {
+	columns := make([]Column, len(h.Fields))
+	for i, field := range h.Fields {
+		columns[i] = Column{Name: field, Index: i}
+		if i < len(h.originalFields) {
+			columns[i].OriginalName = h.originalFields[i]
+		}
+		if i < len(h.Types) {
+			columns[i].Type = fieldTypeName(h, i)
+			dataType := h.Types[i].dataType
+			columns[i].GoType = converterType(dataType, ValueConverters[dataType])
+			columns[i].Container = h.Types[i].container
+			if columns[i].Container {
+				columns[i].GoType = reflect.TypeOf([]interface{}(nil))
+			}
+		}
+	}
+	return columns
+}
+
+// index builds the lookups of FieldIndex and Columns. Headers may be shared
+// between goroutines, so it is called once the fields and types are final
+// rather than lazily by the accessors.
+func (h *Header) index() {
+	h.fieldIndex = make(map[string]int, len(h.Fields))
+	for i, f := range h.Fields {
+		h.fieldIndex[f] = i
+	}
+	h.columns = h.makeColumns()
+}
+
+// ConverterFor returns a function converting a value of the column at idx
+// as a reader with default options does, including the unset and empty
+// sentinels and the elements of containers, or nil if there is no such
+// column.
+func (h *Header) ConverterFor(idx int) func([]byte) (interface{}, error) {
+	if idx < 0 || idx >= len(h.Types) {
+		return nil
+	}
+	fieldType := h.Types[idx]
+	converter := ValueConverters[fieldType.dataType]
+	return func(b []byte) (interface{}, error) {
+		if bytes.Equal(b, h.Unset) || bytes.Equal(b, h.Empty) {
+			return nil, nil
+		}
+		if !fieldType.container {
+			return converter(b)
+		}
+		parts := bytes.Split(b, h.SetSeparator)
+		values := make([]interface{}, len(parts))
+		for i, part := range parts {
+			v, err := converter(part)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		return values, nil
+	}
+}
+
+// WithoutFields returns a copy of h describing records that lack fields.
+func (h *Header) WithoutFields(fields ...string) *Header {
+	drop := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		drop[field] = true
+	}
+	c := h.copy()
+	c.Fields, c.Types, c.originalFields, c.typeNames = nil, nil, nil, nil
+	original := h.OriginalFields()
+	for i, field := range h.Fields {
+		if drop[field] {
+			continue
+		}
+		c.Fields = append(c.Fields, field)
+		c.originalFields = append(c.originalFields, original[i])
+		if i < len(h.Types) {
+			c.Types = append(c.Types, h.Types[i])
+			c.typeNames = append(c.typeNames, fieldTypeName(h, i))
+		}
+	}
+	c.index()
+	return c
+}
+
+// WithFields returns a copy of h describing records with the fields of order,
+// in that order, along with their types.
+func (h *Header) WithFields(order []string) (*Header, error) {
+	c := h.copy()
+	c.Fields, c.Types, c.originalFields, c.typeNames = nil, nil, nil, nil
+	original := h.OriginalFields()
+	seen := make(map[string]bool, len(order))
+	for _, field := range order {
+		i, ok := h.FieldIndex(field)
+		if !ok {
+			return nil, ErrorUnknownField{field}
+		}
+		if seen[field] {
+			return nil, ErrorDuplicateField{Field: field}
+		}
+		seen[field] = true
+		c.Fields = append(c.Fields, field)
+		c.originalFields = append(c.originalFields, original[i])
+		if i < len(h.Types) {
+			c.Types = append(c.Types, h.Types[i])
+			c.typeNames = append(c.typeNames, fieldTypeName(h, i))
+		}
+	}
+	c.index()
+	return c, nil
+}
+
+// AppendField adds a last field of type ft named name, which must not be
+// one of the fields of h.
+func (h *Header) AppendField(name string, ft FieldType) {
+	n := len(h.Fields)
+	original := h.OriginalFields()
+	h.originalFields = append(original[:n:n], name)
+	h.Fields = append(h.Fields[:n:n], name)
+	typeNames := make([]string, len(h.Types), len(h.Types)+1)
+	for i := range h.Types {
+		typeNames[i] = fieldTypeName(h, i)
+	}
+	h.typeNames = append(typeNames, fieldTypeName(&Header{Types: []FieldType{ft}}, 0))
+	h.Types = append(h.Types[:len(h.Types):len(h.Types)], ft)
+	h.index()
+}
+
+// WithRenamedFields returns a copy of h in which the fields named as keys of
+// m are renamed to the corresponding values.
+func (h *Header) WithRenamedFields(m map[string]string) *Header {
+	c := h.copy()
+	c.Fields = make([]string, len(h.Fields))
+	for i, field := range h.Fields {
+		if name, ok := m[field]; ok {
+			field = name
+		}
+		c.Fields[i] = field
+	}
+	c.originalFields = append([]string(nil), h.OriginalFields()...)
+	c.index()
+	return c
+}
+
+// copy returns a shallow copy of h, sharing its lookups.
+func (h *Header) copy() *Header {
+	c := *h
+	return &c
 }
 
 // NewReader creates a new reader.
@@ -95,42 +527,763 @@ func NewReader(r io.Reader) *Reader {
 	return &Reader{parser: NewParser(r)}
 }
 
+// NewReaderFromBufio creates a new reader that reads from r directly, rather
+// than wrapping it in another buffer, so that input the caller peeked from r
+// is read as usual. The reader is not seekable.
+func NewReaderFromBufio(r *bufio.Reader) *Reader {
+	return &Reader{parser: NewSourceParser(&readerSource{r: r, reader: r})}
+}
+
+// NewTeeReader creates a new reader that writes all input it consumes from r
+// to archive, including lines it skips. The input is buffered, so archive
+// may run ahead of the records returned; once Read returns io.EOF and Drain
+// returns, it holds a byte-identical copy of the input. The reader is not
+// seekable.
+func NewTeeReader(r io.Reader, archive io.Writer) *Reader {
+	tee := io.TeeReader(r, archive)
+	reader := NewReader(tee)
+	reader.tee = tee
+	return reader
+}
+
+// NewSourceReader creates a new reader of lines supplied by src. ReadRange
+// requires src to be a LineSeeker.
+func NewSourceReader(src LineSource) *Reader {
+	return &Reader{parser: NewSourceParser(src)}
+}
+
+// NewBytesReader creates a new reader over b. Lines are split in place, so
+// raw values alias b, which must not be modified while the reader is used.
+func NewBytesReader(b []byte) *Reader {
+	return &Reader{parser: NewBytesParser(b)}
+}
+
 // WithKeyTransform configures the reader to transform record keys.
 func (r *Reader) WithKeyTransform(xform KeyTransform) *Reader {
 	r.keyTransform = xform
 	return r
 }
 
+// WithRawColumns configures the reader to add the value of each of fields,
+// as written in the log, to records under the field name suffixed with
+// "_raw".
+func (r *Reader) WithRawColumns(fields ...string) *Reader {
+	r.rawColumns = fields
+	return r
+}
+
+// WithColumnConverter configures the reader to convert the values of field
+// with converter rather than by type. The converter receives the whole
+// value, containers included, and is not called for unset and empty values.
+// The column type reported by Columns is only known for the converters of
+// this package; use WithTypedColumnConverter for others.
+func (r *Reader) WithColumnConverter(field string, converter func(b []byte) (interface{}, error)) *Reader {
+	return r.WithTypedColumnConverter(field, converterTypes[reflect.ValueOf(converter).Pointer()], converter)
+}
+
+// WithTypedColumnConverter is like WithColumnConverter, with typ the type
+// of the values returned by converter.
+func (r *Reader) WithTypedColumnConverter(field string, typ reflect.Type, converter func(b []byte) (interface{}, error)) *Reader {
+	if r.fieldConverters == nil {
+		r.fieldConverters = make(map[string]func(b []byte) (interface{}, error))
+		r.fieldTypes = make(map[string]reflect.Type)
+	}
+	r.fieldConverters[field] = converter
+	r.fieldTypes[field] = typ
+	r.columnConverter = nil
+	return r
+}
+
+// resolveColumnConverters indexes the converters of WithColumnConverter by
+// column.
+func (r *Reader) resolveColumnConverters() error {
+	r.columnConverter = make([]func(b []byte) (interface{}, error), len(r.header.Fields))
+	for field, converter := range r.fieldConverters {
+		i, ok := r.header.FieldIndex(field)
+		if !ok {
+			return ErrorUnknownField{field}
+		}
+		r.columnConverter[i] = converter
+	}
+	return nil
+}
+
+// WithFieldRename configures the reader to rename the fields named as keys
+// of m, as written in #fields, to the corresponding values. Renamed fields
+// are not passed to the key transform, and the new names are the canonical
+// names used by the header and records. Renaming a field to the name of
+// another makes Read fail with ErrorDuplicateField.
+func (r *Reader) WithFieldRename(m map[string]string) *Reader {
+	r.rename = m
+	return r
+}
+
+// PreserveNumericText configures the reader to return time, interval and
+// double values as Numbers holding their original text.
+func (r *Reader) PreserveNumericText(b bool) *Reader {
+	r.numericText = b
+	return r
+}
+
+// HeaderBytes returns the bytes of the header block exactly as read, from
+// the start of the input up to Header.Length, reading the header first if
+// needed. It returns nil if the header cannot be read or the reader was
+// configured not to retain it.
+func (r *Reader) HeaderBytes() []byte {
+	if r.Header() == nil {
+		return nil
+	}
+	return r.header.Raw()
+}
+
+// RetainRawHeader configures whether the reader keeps a copy of the header
+// bytes for Header.Raw. It is enabled by default.
+func (r *Reader) RetainRawHeader(b bool) *Reader {
+	r.discardRaw = !b
+	return r
+}
+
+// WithRecordTransform configures the reader to pass each record through
+// xform before returning it from Read.
+func (r *Reader) WithRecordTransform(xform RecordTransform) *Reader {
+	r.recordTransform = xform
+	return r
+}
+
 // OmitEmpty configures the reader to omit empty fields from returned records.
 func (r *Reader) OmitEmpty(b bool) *Reader {
 	r.omitEmpty = b
 	return r
 }
 
-// Header returns the log meta-info.
+// CloseTimeField is the key holding the #close time in the record emitted
+// by a reader configured with WithCloseRecord.
+const CloseTimeField = "_close_time"
+
+// WithCloseRecord configures the reader to return one final Record holding
+// the #close time under CloseTimeField before returning io.EOF.
+func (r *Reader) WithCloseRecord(b bool) *Reader {
+	r.closeRecord = b
+	return r
+}
+
+// WithSkipComments configures the reader to skip lines starting with '#' in
+// the data section, other than the #close footer, rather than reading them
+// as records.
+func (r *Reader) WithSkipComments(b bool) *Reader {
+	r.skipComments = b
+	return r
+}
+
+// WithCloseMarker configures the prefix of the footer row ending the log,
+// "#close" by default, for logs written by tools using another directive.
+func (r *Reader) WithCloseMarker(marker []byte) *Reader {
+	r.closeMarker = marker
+	return r
+}
+
+// defaultCloseMarker is the prefix of the #close footer row.
+var defaultCloseMarker = []byte("#close")
+
+// marker returns the prefix of the footer row.
+func (r *Reader) marker() []byte {
+	if r.closeMarker == nil {
+		return defaultCloseMarker
+	}
+	return r.closeMarker
+}
+
+// isClose reports whether row is the footer row.
+func (r *Reader) isClose(row Row) bool {
+	return bytes.HasPrefix(row[0], r.marker())
+}
+
+// WithMemoryBudget configures the reader to fail with ErrBudgetExceeded
+// rather than retain more than budget bytes of input, counting the header
+// lines and the values interned by WithEnumInterning. Lines being read are
+// not counted. A budget of 0 means no limit.
+func (r *Reader) WithMemoryBudget(budget int) *Reader {
+	r.budget = budget
+	return r
+}
+
+// retain accounts for n bytes retained by what.
+func (r *Reader) retain(n int, what string) error {
+	if r.budget == 0 {
+		return nil
+	}
+	r.retained += n
+	if r.retained > r.budget {
+		return fmt.Errorf("%w: %s", ErrBudgetExceeded, what)
+	}
+	return nil
+}
+
+// UnsetElements is how a reader handles container elements equal to the
+// unset or empty sentinel, as in "a,-,c" or "1,(empty),2".
+type UnsetElements int
+
+// Ways of handling unset container elements.
+const (
+	UnsetElementsLiteral UnsetElements = iota // convert them as other elements
+	UnsetElementsNil                          // return them as nil
+	UnsetElementsSkip                         // leave them out
+)
+
+// WithUnsetElements configures how the reader handles unset and empty
+// elements of containers, which are converted as other elements by default.
+// Typed containers cannot hold nil, so UnsetElementsNil leaves them out.
+func (r *Reader) WithUnsetElements(mode UnsetElements) *Reader {
+	r.unsetElements = mode
+	return r
+}
+
+// WithMaxLineSize configures the reader to fail with ErrLineTooLong on a
+// line longer than n bytes rather than buffer it whole, bounding the memory
+// an absurdly long line can take. Zero means no limit.
+func (r *Reader) WithMaxLineSize(n int) *Reader {
+	r.parser.SetMaxLineSize(n)
+	return r
+}
+
+// WithColumnCountCheck configures the reader to return an ErrColumnCount
+// for each data line whose column count differs from the number of header
+// fields, rather than failing only on missing columns and ignoring extra
+// ones.
+func (r *Reader) WithColumnCountCheck(b bool) *Reader {
+	r.columnCheck = b
+	return r
+}
+
+// checkColumns checks the column count of the line most recently read.
+func (r *Reader) checkColumns() error {
+	line := bytes.TrimRight(r.parser.line, "\r\n")
+	got := bytes.Count(line, []byte{r.parser.Delimiter}) + 1
+	if got == len(r.header.Fields) {
+		return nil
+	}
+	return ErrColumnCount{
+		Expected: len(r.header.Fields),
+		Got:      got,
+		Row:      append([]byte(nil), line...),
+	}
+}
+
+// WithSetSeparatorFor configures the reader to split containers of
+// dataType elements on sep rather than the set separator of the header.
+func (r *Reader) WithSetSeparatorFor(dataType DataType, sep []byte) *Reader {
+	r.setSeparators[dataType] = sep
+	return r
+}
+
+// setSeparator returns the separator of containers of dataType elements.
+func (r *Reader) setSeparator(dataType DataType) []byte {
+	if sep := r.setSeparators[dataType]; sep != nil {
+		return sep
+	}
+	return r.header.SetSeparator
+}
+
+// WithNumericZeroDefault configures the reader to return unset and empty
+// values of numeric columns as zero, and of numeric containers as empty
+// containers, rather than nil.
+func (r *Reader) WithNumericZeroDefault(b bool) *Reader {
+	r.numericZero = b
+	return r
+}
+
+// ResetConverters configures the reader to convert values with
+// DefaultConverters, regardless of changes to ValueConverters, and undoes
+// WithEnumInterning and NormalizeAddrs.
+func (r *Reader) ResetConverters() *Reader {
+	r.converters = DefaultConverters()
+	r.enums = nil
+	return r
+}
+
+// WithEnumType configures the reader to return enum values as EnumValues
+// rather than strings, so that they can be told apart from string values.
+func (r *Reader) WithEnumType(b bool) *Reader {
+	r.enumType = b
+	return r
+}
+
+// WithEnumInterning configures the reader to intern enum values, so that
+// records share a single string for each distinct value of an enum column.
+// Interned strings are ordinary immutable strings and remain valid after
+// further reads.
+func (r *Reader) WithEnumInterning(b bool) *Reader {
+	if b && r.enums == nil {
+		r.enums = make(map[string]interface{})
+		r.converters[Enum] = r.intern
+	} else if !b {
+		r.enums = nil
+		r.converters[Enum] = nil
+	}
+	return r
+}
+
+// WithMaxRecords configures the reader to return ErrRecordLimit instead of
+// any record past the first n. Zero means no limit.
+func (r *Reader) WithMaxRecords(n uint64) *Reader {
+	r.maxRecords = n
+	return r
+}
+
+// WithTypedContainers configures the reader to return containers of count,
+// int, port, time, double and interval values as []uint64, []int64,
+// []uint16 and []float64 rather than []interface{}. Containers are left
+// untyped when their elements are not converted by the default converter,
+// e.g. when numeric text is preserved.
+func (r *Reader) WithTypedContainers(b bool) *Reader {
+	r.typedContainers = b
+	return r
+}
+
+// RecordsRead returns the number of records returned so far, not counting
+// records dropped by the record transform or the close record.
+func (r *Reader) RecordsRead() uint64 {
+	if r.peeked {
+		return r.peekRecords
+	}
+	return r.records
+}
+
+// BytesRead returns the offset in the input up to which it has been
+// consumed, not counting a peeked record.
+func (r *Reader) BytesRead() uint64 {
+	if r.peeked {
+		return r.peekStart
+	}
+	return r.parser.Offset()
+}
+
+// RecordOffset returns the byte offset of the line of the record most
+// recently read or peeked.
+func (r *Reader) RecordOffset() uint64 {
+	return r.parser.start
+}
+
+// LineNumber returns the line number of the record most recently read or
+// peeked. Lines are counted from 1 at the start of the input or, after
+// ReadRange, at the first line starting at or after its start offset, or
+// following the header if it starts within it.
+func (r *Reader) LineNumber() uint64 {
+	return r.parser.lines
+}
+
+// WithProgress configures the reader to call fn with the number of bytes
+// read so far and total, the size of the input, as reading advances: at
+// most once every 64 KiB, and once more when the log ends.
+func (r *Reader) WithProgress(total uint64, fn func(read, total uint64)) *Reader {
+	r.progressTotal = total
+	r.progressFn = fn
+	return r
+}
+
+// Progress returns the fraction of an input of total bytes consumed so far,
+// between 0 and 1.
+func (r *Reader) Progress(total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	if n := r.BytesRead(); n < uint64(total) {
+		return float64(n) / float64(total)
+	}
+	return 1
+}
+
+// ReadResult describes how reading a log ended.
+type ReadResult struct {
+	FooterSeen  bool      // the #close footer was read
+	CloseTime   time.Time // time of the footer, if it could be parsed
+	Truncated   bool      // the log ended within a line
+	RecordsRead uint64
+	BytesRead   uint64
+	LastOffset  uint64 // offset of the last line read
+}
+
+// Result describes how reading the log ended. It is meant to be called
+// once Read has returned an error.
+func (r *Reader) Result() ReadResult {
+	result := ReadResult{
+		FooterSeen:  r.footerSeen,
+		Truncated:   r.truncated,
+		RecordsRead: r.records,
+		BytesRead:   r.BytesRead(),
+		LastOffset:  r.parser.start,
+	}
+	if r.header != nil {
+		result.CloseTime = r.header.Close
+	}
+	return result
+}
+
+// Duration returns the span of time the log covers, from its #open to its
+// #close time, once the #close footer has been read. It returns false if
+// either time is missing or could not be parsed.
+func (r *Reader) Duration() (time.Duration, bool) {
+	if r.header == nil || r.header.Open.IsZero() || r.header.Close.IsZero() {
+		return 0, false
+	}
+	return r.header.Close.Sub(r.header.Open), true
+}
+
+// Header returns the log meta-info, reading the header first if needed. It
+// returns nil if the header cannot be read, in which case Read returns the
+// error.
 func (r *Reader) Header() *Header {
+	if r.header == nil && r.headerErr == nil {
+		if r.knownHeader != nil {
+			r.header, r.headerErr = r.skipKnownHeader()
+		}
+		if r.header == nil && r.headerErr == nil {
+			r.header, r.headerErr = r.readHeader()
+			if r.knownHeader != nil && r.header != nil && !sameSchema(r.header, r.knownHeader) {
+				r.warn("header differs from the known header", "fields", len(r.header.Fields))
+			}
+		}
+		// Both leave the first data row current, unless the log ends with
+		// its header.
+		r.pendingRow = r.headerErr == nil && len(r.parser.Line()) > 0
+	}
 	return r.header
 }
 
+// Columns returns a description of each column in the log, or nil if the
+// header cannot be read. Column types reflect the reader options.
+func (r *Reader) Columns() []Column {
+	if r.Header() == nil {
+		return nil
+	}
+	columns := append([]Column(nil), r.header.Columns()...)
+	for i := range columns {
+		if i >= len(r.header.Types) {
+			continue
+		}
+		if columns[i].Container {
+			if typ := r.typedContainer(r.header.Types[i].dataType); typ != nil {
+				columns[i].GoType = typ
+			}
+			continue
+		}
+		_, columns[i].GoType = r.converter(r.header.Types[i].dataType)
+		if r.ecsTypes == nil {
+			continue
+		}
+		switch r.ecsTypes[i] {
+		case "date":
+			columns[i].GoType = reflect.TypeOf("")
+		case "duration":
+			columns[i].GoType = reflect.TypeOf(int64(0))
+		}
+	}
+	for field, typ := range r.fieldTypes {
+		if i, ok := r.header.FieldIndex(field); ok {
+			columns[i].GoType = typ
+		}
+	}
+	return columns
+}
+
 func (r *Reader) Read() (Record, error) {
-	var row Row
-	var err error
+	if r.peeked {
+		r.peeked = false
+		return r.peek, r.peekErr
+	}
+	if r.closed {
+		return nil, io.EOF
+	}
+	for {
+		row, err := r.next()
+		if row == nil {
+			return nil, err
+		}
+		if r.isClose(row) {
+			return r.close(row)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if r.skipComment(row) {
+			continue
+		}
+		if r.maxRecords != 0 && r.records >= r.maxRecords {
+			return nil, ErrRecordLimit
+		}
+		if r.rejectBinary && r.isBinary(r.parser.line) {
+			r.warn("corrupt line", "length", len(r.parser.line))
+			return nil, CorruptLineError{Offset: r.parser.start, Length: len(r.parser.line)}
+		}
+		record, err := r.newRecord(row)
+		if err != nil || record != nil {
+			if record != nil {
+				r.records++
+				r.countFlags()
+			}
+			return record, err
+		}
+	}
+}
+
+// Peek returns the next record without consuming it, so that the next call
+// to Read returns the same record and error.
+func (r *Reader) Peek() (Record, error) {
+	if !r.peeked {
+		r.peekRecords = r.records
+		r.peekFlagCounts = r.flagCounts
+		r.peek, r.peekErr = r.Read()
+		r.peekStart = r.parser.start
+		r.peeked = true
+	}
+	return r.peek, r.peekErr
+}
+
+// unpeek discards the peeked record, uncounting it.
+func (r *Reader) unpeek() {
+	r.peeked = false
+	r.records = r.peekRecords
+	r.flagCounts = r.peekFlagCounts
+}
+
+// Skip reads and discards the next n records without converting them,
+// returning io.EOF if the log ends first. Skipped records are not passed to
+// the record transform nor counted by RecordsRead.
+func (r *Reader) Skip(n int) error {
+	if r.peeked && n > 0 {
+		r.unpeek()
+		if r.peekErr != nil {
+			return r.peekErr
+		}
+		n--
+	}
+	if r.closed {
+		return io.EOF
+	}
+	for i := 0; i < n; i++ {
+		row, err := r.next()
+		if row == nil {
+			return err
+		}
+		if r.isClose(row) {
+			r.close(row)
+			return io.EOF
+		}
+		if err != nil {
+			return err
+		}
+		if r.skipComment(row) {
+			i--
+		}
+	}
+	return nil
+}
+
+// skipComment reports whether row is a comment line to be skipped. As the
+// comment may have been the first row split, the column count is reset.
+func (r *Reader) skipComment(row Row) bool {
+	if !r.skipComments || !bytes.HasPrefix(row[0], []byte("#")) {
+		return false
+	}
+	r.parser.ResetRow()
+	return true
+}
+
+// next returns the next row, reading the header first if needed. A final
+// row lacking its newline is returned along with io.EOF.
+func (r *Reader) next() (Row, error) {
+	if r.header == nil || r.pendingRow {
+		if r.Header() == nil {
+			r.reportProgress(true)
+			return nil, r.headerErr
+		}
+		if r.pendingRow {
+			r.pendingRow = false
+			r.reportProgress(false)
+			return r.parser.Current(), nil
+		}
+	}
+	row, err := r.parser.Read()
+	r.reportProgress(err != nil)
+	if err != nil && !(err == io.EOF && row != nil) {
+		r.truncated = err == ErrTruncatedLine
+		return nil, err
+	}
+	return row, err
+}
+
+// progressInterval is the minimum number of bytes read between calls of the
+// WithProgress callback.
+const progressInterval = 64 << 10
+
+// reportProgress calls the progress callback if enough input was read since
+// the last call, or if force is set.
+func (r *Reader) reportProgress(force bool) {
+	if r.progressFn == nil {
+		return
+	}
+	offset := r.parser.offset
+	if offset < r.progressNext && !force {
+		return
+	}
+	r.progressNext = offset + progressInterval
+	r.progressFn(offset, r.progressTotal)
+}
+
+// ReadAll reads all remaining records. It returns the records read so far
+// along with any error other than io.EOF, including ErrRecordLimit.
+func (r *Reader) ReadAll() ([]Record, error) {
+	var records []Record
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
+
+// Dump reads all remaining records, returning them along with the header.
+// The header is nil if it could not be read.
+func (r *Reader) Dump() (*Header, []Record, error) {
+	records, err := r.ReadAll()
+	return r.header, records, err
+}
 
+// ReadRange reads the records stored between the byte offsets start and end
+// of the input, which must implement io.Seeker unless the reader was
+// created by NewBytesReader. Only complete records are
+// returned: a start offset inside a line skips to the following line, and
+// the record straddling end is left for the next Read.
+func (r *Reader) ReadRange(start, end uint64) ([]Record, error) {
+	if r.peeked {
+		r.unpeek()
+	}
+	r.pendingRow = false
 	if r.header == nil {
-		r.header, err = r.readHeader()
+		if err := r.seek(0); err != nil {
+			return nil, err
+		}
+		header, err := r.readHeader()
 		if err != nil {
 			return nil, err
 		}
-		row = r.parser.Current()
-	} else {
-		row, err = r.parser.Read()
+		r.header = header
+	}
+	if start < r.header.Length {
+		start = r.header.Length
+	}
+	if start > r.header.Length {
+		// Consume the remainder of the line preceding start, which
+		// leaves the parser on the first line boundary at or after it.
+		if err := r.seek(start - 1); err != nil {
+			return nil, err
+		}
+		line, err := r.parser.readLine()
+		r.parser.offset += uint64(len(line))
+		if err == io.EOF {
+			return nil, nil
+		}
 		if err != nil {
 			return nil, err
 		}
+	} else if err := r.seek(start); err != nil {
+		return nil, err
 	}
-	if bytes.HasPrefix(row[0], []byte("#close")) {
+
+	var records []Record
+	for {
+		row, err := r.parser.Read()
+		r.truncated = err == ErrTruncatedLine
+		if r.parser.Offset() > end {
+			// Rewind so the next Read returns this line, under the same
+			// line number.
+			lines := r.parser.lines - 1
+			if err := r.seek(r.parser.start); err != nil {
+				return records, err
+			}
+			r.parser.lines = lines
+			return records, nil
+		}
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		if r.isClose(row) {
+			return records, r.seek(r.parser.start)
+		}
+		if r.skipComment(row) {
+			continue
+		}
+		if r.maxRecords != 0 && r.records >= r.maxRecords {
+			return records, ErrRecordLimit
+		}
+		record, err := r.newRecord(row)
+		if err != nil {
+			return records, err
+		}
+		if record != nil {
+			records = append(records, record)
+			r.records++
+			r.countFlags()
+		}
+	}
+}
+
+// Drain reads the input of a reader created by NewTeeReader up to the end,
+// so that any data following the footer is archived as well. It must only
+// be called once Read has returned io.EOF, and blocks until the underlying
+// reader does. It does nothing for other readers.
+func (r *Reader) Drain() error {
+	if r.tee == nil {
+		return nil
+	}
+	_, err := io.Copy(io.Discard, r.tee)
+	return err
+}
+
+// close records the time of the #close footer row in the header, returning
+// the close record if one was requested and io.EOF otherwise.
+func (r *Reader) close(row Row) (Record, error) {
+	r.footerSeen = true
+	r.reportProgress(true)
+	if len(row) > 1 {
+		var layout string
+		r.header.Close, layout = r.parseTime(string(row[1]))
+		if layout == "" {
+			r.warn("unparseable time", "field", string(r.marker()), "value", snippet(row[1]))
+		}
+	}
+	if !r.closeRecord {
 		return nil, io.EOF
 	}
+	r.closed = true
+	return Record{CloseTimeField: r.header.Close}, nil
+}
+
+func (r *Reader) seek(offset uint64) error {
+	return r.parser.seek(offset)
+}
+
+// newRecord converts row to a Record, returning a nil Record if the record
+// transform dropped it.
+func (r *Reader) newRecord(row Row) (Record, error) {
+	r.flags = 0
+	if r.fieldConverters != nil && r.columnConverter == nil {
+		if err := r.resolveColumnConverters(); err != nil {
+			return nil, err
+		}
+	}
+	if r.columnCheck {
+		if err := r.checkColumns(); err != nil {
+			return nil, err
+		}
+	}
 	record := make(Record, len(r.header.Fields))
 	for i := 0; i < len(r.header.Fields); i++ {
 		v, err := r.readValue(row, i)
@@ -141,29 +1294,109 @@ func (r *Reader) Read() (Record, error) {
 			record[r.header.Fields[i]] = v
 		}
 	}
+	for _, field := range r.rawColumns {
+		i, ok := r.header.FieldIndex(field)
+		if !ok {
+			return nil, ErrorUnknownField{field}
+		}
+		record[field+"_raw"] = string(row[i])
+	}
+	if r.hashKey != "" {
+		sum, err := r.recordHash(row)
+		if err != nil {
+			return nil, err
+		}
+		record[r.hashKey] = sum
+	}
+	if r.recordTransform != nil {
+		var err error
+		if record, err = r.recordTransform(record); err != nil {
+			return nil, fmt.Errorf("record transform: %w", err)
+		}
+	}
 	return record, nil
 }
 
+// ReadHeaderOnly reads the header of the log in r, stopping before the
+// first data row.
+func ReadHeaderOnly(r io.Reader) (*Header, error) {
+	reader := NewReader(r)
+	reader.headerOnly = true
+	return reader.readHeader()
+}
+
+// utf8BOM is the UTF-8 encoded byte order mark.
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+var gzipMagic = []byte("\x1f\x8b")
+
 func (r *Reader) readHeader() (*Header, error) {
+	if r.interchange {
+		return r.readInterchangeHeader()
+	}
 	header := Header{}
+	started := false
 	for {
+		if r.headerOnly && started {
+			marker := r.marker()
+			next := r.parser.peek(len(marker))
+			if len(next) == 0 || next[0] != '#' || bytes.Equal(next, marker) {
+				header.Length = r.parser.offset
+				break
+			}
+		}
 		row, err := r.parser.Read()
-		if err != nil {
+		if r.parser.start == 0 && bytes.HasPrefix(r.parser.line, gzipMagic) {
+			return nil, ErrCompressedInput
+		}
+		if started && err == io.EOF && (row == nil || r.isClose(row)) {
+			// A log without records ends after its header.
+			header.Length = r.parser.start
+			break
+		}
+		if err != nil && !(r.headerOnly && err == io.EOF && row != nil) {
 			return nil, err
 		}
 		r.parser.ResetRow()
 
-		if !bytes.HasPrefix(row[0], []byte("#")) {
+		if !started {
+			// Skip a UTF-8 byte order mark and blank lines preceding the
+			// header, as left behind by some Windows tooling.
+			if r.parser.start == 0 {
+				row[0] = bytes.TrimPrefix(row[0], utf8BOM)
+			}
+			content := bytes.TrimSpace(bytes.TrimPrefix(r.parser.line, utf8BOM))
+			if bytes.HasPrefix(content, []byte("{")) {
+				return nil, ErrJSONFormatDetected
+			}
+			if len(content) == 0 {
+				if err := r.retain(len(r.parser.line), "header"); err != nil {
+					return nil, err
+				}
+				if !r.discardRaw {
+					header.raw = append(header.raw, r.parser.line...)
+				}
+				continue
+			}
+			started = true
+		}
+		if !bytes.HasPrefix(row[0], []byte("#")) || r.isClose(row) {
+			header.Length = r.parser.start
 			break
 		}
+		if err := r.retain(len(r.parser.line), "header"); err != nil {
+			return nil, err
+		}
+		if !r.discardRaw {
+			header.raw = append(header.raw, r.parser.line...)
+		}
 		if bytes.HasPrefix(row[0], []byte("#separator")) {
-			parts := bytes.Split(row[0], []byte(" "))
-			encodedSeparator := parts[1]
-			sep, err := hex.DecodeString(string(encodedSeparator[2:]))
+			sep, err := readSeparator(row[0])
 			if err != nil {
-				return nil, ErrInvalidSeparator
+				return nil, err
 			}
-			header.Separator = sep[0]
+			header.Separator = sep
+			r.parser.Delimiter = sep
 			continue
 		}
 		switch string(row[0]) {
@@ -174,37 +1407,126 @@ func (r *Reader) readHeader() (*Header, error) {
 		case "#empty_field":
 			header.Empty = append(header.Empty, row[1]...)
 		case "#fields":
-			for _, f := range row[1:] {
-				field := string(f)
-				if r.keyTransform != nil {
-					field = r.keyTransform(field)
-				}
-				header.Fields = append(header.Fields, field)
+			if r.headerCache != nil {
+				err = r.addCachedFields(&header, r.parser.line, row[1:])
+			} else {
+				err = r.addFields(&header, row[1:])
+			}
+			if err != nil {
+				return nil, err
 			}
 		case "#types":
-			for _, t := range row[1:] {
-				fieldType, err := readFieldType(string(t))
-				if err != nil {
-					return nil, err
-				}
-				header.Types = append(header.Types, fieldType)
+			if r.headerCache != nil {
+				err = r.addCachedTypes(&header, r.parser.line, row[1:])
+			} else {
+				err = addTypes(&header, row[1:])
+			}
+			if err != nil {
+				return nil, err
 			}
 		case "#path":
-			header.Path = string(row[1][:])
+			if len(row) < 2 {
+				r.warn("missing header value", "directive", "#path")
+				continue
+			}
+			// A path holding the separator is split across columns.
+			header.Path = string(bytes.Join(row[1:], []byte{r.parser.Delimiter}))
+		case "#open":
+			if len(row) < 2 {
+				r.warn("missing header value", "directive", "#open")
+				continue
+			}
+			header.Open, header.OpenLayout = r.parseTime(string(row[1]))
+			if header.OpenLayout == "" {
+				r.warn("unparseable time", "field", "#open", "value", snippet(row[1]))
+			}
+		default:
+			r.warn("unknown header directive", "directive", snippet(row[0]))
 		}
 	}
+	if bytes.IndexByte(header.SetSeparator, r.parser.Delimiter) >= 0 {
+		return nil, fmt.Errorf("%w: set separator %q holds the separator", ErrInvalidSeparator, header.SetSeparator)
+	}
+	if r.ecs {
+		r.applyECSMapping(&header)
+	}
+	header.index()
 	return &header, nil
 }
 
+// readSeparator decodes the separator of a "#separator \xHH" directive. The
+// separator may not be a byte that delimits lines, directives or escapes.
+func readSeparator(directive []byte) (byte, error) {
+	parts := bytes.Split(directive, []byte(" "))
+	if len(parts) != 2 || !bytes.HasPrefix(parts[1], []byte(`\x`)) {
+		return 0, ErrInvalidSeparator
+	}
+	sep, err := hex.DecodeString(string(parts[1][2:]))
+	if err != nil || len(sep) != 1 {
+		return 0, ErrInvalidSeparator
+	}
+	switch sep[0] {
+	case '#', '\n', '\\':
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSeparator, sep[0])
+	}
+	return sep[0], nil
+}
+
+// addFields appends the names in row to the fields of header. It fails if a
+// field is renamed to the name of another field.
+func (r *Reader) addFields(header *Header, row Row) error {
+	var renamed []string
+	for _, f := range row {
+		field := string(f)
+		header.originalFields = append(header.originalFields, field)
+		if name, ok := r.rename[field]; ok {
+			field = name
+			renamed = append(renamed, name)
+		} else if r.keyTransform != nil {
+			field = r.keyTransform(field)
+		}
+		header.Fields = append(header.Fields, field)
+	}
+	for _, name := range renamed {
+		var n int
+		for _, field := range header.Fields {
+			if field == name {
+				n++
+			}
+		}
+		if n > 1 {
+			return ErrorDuplicateField{Field: name}
+		}
+	}
+	return nil
+}
+
+// addTypes appends the type names in row to the types of header.
+func addTypes(header *Header, row Row) error {
+	for _, t := range row {
+		fieldType, err := readFieldType(string(t))
+		if err != nil {
+			return err
+		}
+		header.Types = append(header.Types, fieldType)
+		header.typeNames = append(header.typeNames, string(t))
+	}
+	return nil
+}
+
 func readFieldType(s string) (FieldType, error) {
-	var container bool
-	if strings.HasSuffix(s, "]") {
-		start := strings.Index(s, "[")
-		end := strings.Index(s[start:], "]")
-		s = s[start+1 : start+end]
-		container = true
-	}
-	if dataType, ok := dataTypeLookup[s]; ok {
+	name, container := s, false
+	if open := strings.IndexByte(s, '['); open >= 0 {
+		// A container: a kind such as vector and a bracketed element type.
+		if open == 0 || !strings.HasSuffix(s, "]") || strings.IndexByte(s[:open], ']') >= 0 {
+			return FieldType{}, ErrorInvalidFieldType{TypeName: s}
+		}
+		name, container = s[open+1:len(s)-1], true
+	}
+	if strings.ContainsAny(name, "[]") {
+		return FieldType{}, ErrorInvalidFieldType{TypeName: s}
+	}
+	if dataType, ok := dataTypeLookup[name]; ok {
 		return FieldType{
 			dataType:  dataType,
 			container: container,
@@ -217,20 +1539,142 @@ func (r *Reader) readValue(row Row, idx int) (interface{}, error) {
 	if idx >= len(row) {
 		return nil, ErrTruncatedLine
 	}
-	if bytes.Equal(row[idx], r.header.Unset) {
+	if r.isSentinel(row[idx]) {
+		r.markSentinel(row[idx])
+		if r.numericZero {
+			v := r.zeroValue(r.header.Types[idx])
+			if v != nil {
+				r.flags |= HadConversionFallback
+			}
+			return v, nil
+		}
 		return nil, nil
 	}
-	if bytes.Equal(row[idx], r.header.Empty) {
-		if r.header.Types[idx].container {
-			return nil, nil
+	if r.columnConverter != nil && r.columnConverter[idx] != nil {
+		if r.unescape {
+			return r.columnConverter[idx](unescape(row[idx]))
 		}
-		return nil, nil
+		return r.columnConverter[idx](row[idx])
+	}
+	if r.ecsTypes != nil && r.ecsTypes[idx] != "" {
+		v, err := r.convertValue(row, idx)
+		if err != nil {
+			return nil, err
+		}
+		return ECSValue(r.ecsTypes[idx], v)
+	}
+	return r.convertValue(row, idx)
+}
+
+// converter returns the converter for values of dataType and the type of
+// the values it returns.
+func (r *Reader) converter(dataType DataType) (func(b []byte) (interface{}, error), reflect.Type) {
+	if r.numericText {
+		switch dataType {
+		case Time, Double, Interval:
+			return ToNumber, reflect.TypeOf(Number(""))
+		}
+	}
+	if dataType == Enum && r.enumType {
+		if r.enums != nil {
+			return r.converters[Enum], reflect.TypeOf(EnumValue(""))
+		}
+		return ToEnumValue, reflect.TypeOf(EnumValue(""))
+	}
+	if r.converters[dataType] != nil {
+		return r.converters[dataType], converterType(dataType, r.converters[dataType])
 	}
-	converter := ValueConverters[r.header.Types[idx].dataType]
+	return ValueConverters[dataType], converterType(dataType, ValueConverters[dataType])
+}
+
+// intern converts input to a string or EnumValue, returning the same value
+// for all occurrences of an input.
+func (r *Reader) intern(b []byte) (interface{}, error) {
+	if v, ok := r.enums[string(b)]; ok {
+		return v, nil
+	}
+	if err := r.retain(len(b), "enum interning"); err != nil {
+		return nil, err
+	}
+	s := string(b)
+	var v interface{} = s
+	if r.enumType {
+		v = EnumValue(s)
+	}
+	r.enums[s] = v
+	return v, nil
+}
+
+// zeroValue returns the value of an unset or empty column of type
+// fieldType with WithNumericZeroDefault: the zero value of numeric types,
+// or an empty container of them, and nil for other types and for
+// converters whose type is unknown.
+func (r *Reader) zeroValue(fieldType FieldType) interface{} {
+	switch fieldType.dataType {
+	case Time, Port, Int, Double, Count, Interval:
+	default:
+		return nil
+	}
+	if fieldType.container {
+		if typ := r.typedContainer(fieldType.dataType); typ != nil {
+			return reflect.MakeSlice(typ, 0, 0).Interface()
+		}
+		return []interface{}{}
+	}
+	_, typ := r.converter(fieldType.dataType)
+	switch typ {
+	case nil:
+		return nil
+	case reflect.TypeOf(Number("")):
+		return Number("0")
+	}
+	return reflect.Zero(typ).Interface()
+}
+
+// isSentinel reports whether b is the unset or empty sentinel.
+func (r *Reader) isSentinel(b []byte) bool {
+	return bytes.Equal(b, r.header.Unset) || bytes.Equal(b, r.header.Empty)
+}
+
+// skipSentinels removes the unset and empty elements from parts.
+func (r *Reader) skipSentinels(parts [][]byte) [][]byte {
+	kept := parts[:0]
+	for _, part := range parts {
+		if !r.isSentinel(part) {
+			kept = append(kept, part)
+		}
+	}
+	if len(kept) < len(parts) {
+		r.flags |= HadConversionFallback
+	}
+	return kept
+}
+
+func (r *Reader) convertValue(row Row, idx int) (interface{}, error) {
+	converter, _ := r.converter(r.header.Types[idx].dataType)
 	if r.header.Types[idx].container {
-		parts := bytes.Split(row[idx], r.header.SetSeparator)
+		var parts [][]byte
+		if r.unescape {
+			parts = splitEscaped(row[idx], r.setSeparator(r.header.Types[idx].dataType))
+			for i := range parts {
+				parts[i] = unescape(parts[i])
+			}
+		} else {
+			parts = bytes.Split(row[idx], r.setSeparator(r.header.Types[idx].dataType))
+		}
+		typed := r.typedContainer(r.header.Types[idx].dataType) != nil
+		if r.unsetElements == UnsetElementsSkip || r.unsetElements == UnsetElementsNil && typed {
+			parts = r.skipSentinels(parts)
+		}
+		if typed {
+			return convertTyped(r.header.Types[idx].dataType, parts)
+		}
 		res := make([]interface{}, len(parts))
 		for i := 0; i < len(parts); i++ {
+			if r.unsetElements == UnsetElementsNil && r.isSentinel(parts[i]) {
+				r.flags |= HadConversionFallback
+				continue
+			}
 			v, err := converter(parts[i])
 			if err != nil {
 				return nil, err
@@ -239,9 +1683,95 @@ func (r *Reader) readValue(row Row, idx int) (interface{}, error) {
 		}
 		return res, nil
 	}
+	if r.unescape {
+		return converter(unescape(row[idx]))
+	}
 	return converter(row[idx])
 }
 
+// typedContainer returns the type of the typed slice holding a container of
+// dataType values, or nil if the container is returned as []interface{}.
+func (r *Reader) typedContainer(dataType DataType) reflect.Type {
+	if !r.typedContainers {
+		return nil
+	}
+	// The elements are only typed as the default converter would return them.
+	if convert, _ := r.converter(dataType); !isDefaultConverter(dataType, convert) {
+		return nil
+	}
+	switch dataType {
+	case Count:
+		return reflect.TypeOf([]uint64(nil))
+	case Int:
+		return reflect.TypeOf([]int64(nil))
+	case Port:
+		return reflect.TypeOf([]uint16(nil))
+	case Time, Double, Interval:
+		return reflect.TypeOf([]float64(nil))
+	}
+	return nil
+}
+
+// convertTyped converts the elements of a numeric container to the slice
+// type returned by typedContainer.
+func convertTyped(dataType DataType, parts [][]byte) (interface{}, error) {
+	var err error
+	switch dataType {
+	case Count:
+		res := make([]uint64, len(parts))
+		for i, b := range parts {
+			if res[i], err = strconv.ParseUint(btos(b), 10, 64); err != nil {
+				return nil, elementError(b, err)
+			}
+		}
+		return res, nil
+	case Int:
+		res := make([]int64, len(parts))
+		for i, b := range parts {
+			if res[i], err = strconv.ParseInt(btos(b), 10, 64); err != nil {
+				return nil, elementError(b, err)
+			}
+		}
+		return res, nil
+	case Port:
+		res := make([]uint16, len(parts))
+		for i, b := range parts {
+			v, err := strconv.ParseUint(btos(b), 10, 16)
+			if err != nil {
+				return nil, elementError(b, err)
+			}
+			res[i] = uint16(v)
+		}
+		return res, nil
+	}
+	res := make([]float64, len(parts))
+	for i, b := range parts {
+		if res[i], err = strconv.ParseFloat(btos(b), 64); err != nil {
+			return nil, elementError(b, err)
+		}
+	}
+	return res, nil
+}
+
+func elementError(b []byte, err error) error {
+	return fmt.Errorf("%w: element %q: %v", ErrConvert, b, err.(*strconv.NumError).Err)
+}
+
+// converterTypes maps the converters of this package to the types of the
+// values they return.
+var converterTypes = map[uintptr]reflect.Type{
+	reflect.ValueOf(AsBytes).Pointer():     reflect.TypeOf([]byte(nil)),
+	reflect.ValueOf(ToString).Pointer():    reflect.TypeOf(""),
+	reflect.ValueOf(ToUint16).Pointer():    reflect.TypeOf(uint16(0)),
+	reflect.ValueOf(ToJSONRaw).Pointer():   reflect.TypeOf(json.RawMessage(nil)),
+	reflect.ValueOf(ToEnumValue).Pointer(): reflect.TypeOf(EnumValue("")),
+	reflect.ValueOf(ToInt64).Pointer():     reflect.TypeOf(int64(0)),
+	reflect.ValueOf(ToUint64).Pointer():    reflect.TypeOf(uint64(0)),
+	reflect.ValueOf(ToFloat64).Pointer():   reflect.TypeOf(float64(0)),
+	reflect.ValueOf(ToNumber).Pointer():    reflect.TypeOf(Number("")),
+	reflect.ValueOf(ToBool).Pointer():      reflect.TypeOf(false),
+}
+
 func btos(b []byte) string {
 	return *(*string)(unsafe.Pointer(&b))
 }
@@ -259,7 +1789,24 @@ func ToString(b []byte) (interface{}, error) {
 // ToUint16 converter converts input to uint16.
 func ToUint16(b []byte) (interface{}, error) {
 	i, err := strconv.ParseUint(btos(b), 10, 16)
-	return uint16(i), err
+	if err != nil {
+		return nil, fmt.Errorf("%w: port %q: %v", ErrConvert, b, err.(*strconv.NumError).Err)
+	}
+	return uint16(i), nil
+}
+
+// ToJSONRaw converter converts input holding JSON to a json.RawMessage, so
+// that it is marshaled as is rather than as a string.
+func ToJSONRaw(b []byte) (interface{}, error) {
+	if !json.Valid(b) {
+		return nil, fmt.Errorf("%w: invalid JSON %q", ErrConvert, snippet(b))
+	}
+	return json.RawMessage(append([]byte(nil), b...)), nil
+}
+
+// ToEnumValue converter converts input to an EnumValue.
+func ToEnumValue(b []byte) (interface{}, error) {
+	return EnumValue(b), nil
 }
 
 // ToInt64 converter converts input to int64.
@@ -277,6 +1824,19 @@ func ToFloat64(b []byte) (interface{}, error) {
 	return strconv.ParseFloat(btos(b), 64)
 }
 
+// ToNumber converter converts input to a Number. The input must be a JSON
+// number, or nan or an infinity as zeek writes them.
+func ToNumber(b []byte) (interface{}, error) {
+	f, err := strconv.ParseFloat(btos(b), 64)
+	if err != nil {
+		return nil, err
+	}
+	if !isJSONNumber(btos(b)) && !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return nil, &strconv.NumError{Func: "ParseFloat", Num: string(b), Err: strconv.ErrSyntax}
+	}
+	return Number(b), nil
+}
+
 // ToBool converter converts input to bool.
 func ToBool(b []byte) (interface{}, error) {
 	return bytes.Equal(b, []byte("T")), nil
@@ -1,12 +1,19 @@
 package tsv
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 var input = `#separator \x09
@@ -95,6 +102,369 @@ func TestReadHeader(t *testing.T) {
 	}
 }
 
+func TestReadHeaderOnly(t *testing.T) {
+	expected, err := NewReader(strings.NewReader(input)).readHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	head := input[:expected.Length]
+	var tests = map[string]io.Reader{
+		"log":        strings.NewReader(input),
+		"no data":    io.MultiReader(strings.NewReader(head), iotest.ErrReader(errors.New("data read"))),
+		"empty log":  strings.NewReader(head + "#close\t2019-01-01-00-00-01\n"),
+		"no newline": strings.NewReader(strings.TrimSuffix(head, "\n")),
+	}
+	for name, r := range tests {
+		t.Run(name, func(t *testing.T) {
+			header, err := ReadHeaderOnly(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(header.Fields, expected.Fields) || !reflect.DeepEqual(header.Types, expected.Types) {
+				t.Errorf("expected fields %v, got %v", expected.Fields, header.Fields)
+			}
+			if name != "no newline" && header.Length != expected.Length {
+				t.Errorf("expected header length %d, got %d", expected.Length, header.Length)
+			}
+		})
+	}
+}
+
+func TestHeaderPath(t *testing.T) {
+	var tests = []struct {
+		line, path string
+		warnings   int
+	}{
+		{"#path\ttest", "test", 0},
+		{"#path", "", 1},
+		{"#path\tmy\tpath", "my\tpath", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			in := strings.Replace(input, "#path\ttest", tt.line, 1)
+			var warnings int
+			reader := NewReader(strings.NewReader(in)).
+				WithLogger(func(level, msg string, kv ...interface{}) { warnings++ })
+			header, err := reader.readHeader()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header.Path != tt.path || warnings != tt.warnings {
+				t.Errorf("expected path %q with %d warnings, got %q with %d", tt.path, tt.warnings, header.Path, warnings)
+			}
+		})
+	}
+}
+
+func TestEmptyLog(t *testing.T) {
+	header := input[:strings.Index(input, "1546304400")]
+	var tests = []struct {
+		name, in string
+		close    time.Time
+	}{
+		{"closed", header + "#close\t2019-01-01-00-00-01\n", time.Date(2019, 1, 1, 0, 0, 1, 0, time.UTC)},
+		{"no newline", header + "#close\t2019-01-01-00-00-01", time.Date(2019, 1, 1, 0, 0, 1, 0, time.UTC)},
+		{"unclosed", header, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var warnings int
+			reader := NewReader(strings.NewReader(tt.in)).
+				WithLogger(func(level, msg string, kv ...interface{}) { warnings++ })
+			h := reader.Header()
+			if h == nil {
+				t.Fatal("expected a header")
+			}
+			if h.Length != uint64(len(header)) || len(h.Fields) != 11 {
+				t.Errorf("expected %d header bytes and 11 fields, got %d and %d", len(header), h.Length, len(h.Fields))
+			}
+			if record, err := reader.Read(); err != io.EOF {
+				t.Errorf("expected io.EOF, got %v, %v", record, err)
+			}
+			// Reading first reads the header too.
+			if record, err := NewReader(strings.NewReader(tt.in)).Read(); err != io.EOF {
+				t.Errorf("expected io.EOF reading first, got %v, %v", record, err)
+			}
+			if !h.Close.Equal(tt.close) || warnings != 0 {
+				t.Errorf("expected close time %v with no warnings, got %v with %d", tt.close, h.Close, warnings)
+			}
+		})
+	}
+}
+
+func TestHeaderOpenMissing(t *testing.T) {
+	in := strings.Replace(input, "#open\t2019-01-01-00-00-00", "#open", 1)
+	var warnings int
+	reader := NewReader(strings.NewReader(in)).
+		WithLogger(func(level, msg string, kv ...interface{}) { warnings++ })
+	header, err := reader.readHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !header.Open.IsZero() || warnings != 1 {
+		t.Errorf("expected zero open time with 1 warning, got %v with %d", header.Open, warnings)
+	}
+}
+
+func TestRawHeader(t *testing.T) {
+	reader := NewReader(strings.NewReader(input))
+	if _, err := reader.Read(); err != nil {
+		t.Fatal(err)
+	}
+	header := reader.Header()
+	if uint64(len(header.Raw())) != header.Length {
+		t.Errorf("expected %d raw header bytes, got %d", header.Length, len(header.Raw()))
+	}
+	if string(header.Raw())+input[header.Length:] != input {
+		t.Error("raw header does not reproduce the input")
+	}
+	if !bytes.Equal(reader.HeaderBytes(), header.Raw()) {
+		t.Error("expected HeaderBytes to return the raw header")
+	}
+
+	// Skipping a known header keeps the bytes skipped.
+	same := strings.Replace(input, "#path\ttest", "#path\tpath", 1)
+	known := NewReader(strings.NewReader(same)).WithKnownHeader(header)
+	if raw := string(known.HeaderBytes()); raw != same[:header.Length] {
+		t.Errorf("expected the skipped header, got %q", raw)
+	}
+
+	reader = NewReader(strings.NewReader(input)).RetainRawHeader(false)
+	if _, err := reader.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if reader.Header().Raw() != nil {
+		t.Error("expected raw header not to be retained")
+	}
+	if reader.Header().Length != header.Length {
+		t.Errorf("expected header length %d, got %d", header.Length, reader.Header().Length)
+	}
+}
+
+func TestLeadingGarbage(t *testing.T) {
+	base := NewReader(strings.NewReader(input))
+	expected := collect(base)
+	for _, prefix := range []string{"\xef\xbb\xbf", "\n", "\r\n\n", "\xef\xbb\xbf\n"} {
+		in := prefix + input
+		for name, reader := range map[string]*Reader{
+			"stream": NewReader(strings.NewReader(in)),
+			"bytes":  NewBytesReader([]byte(in)),
+		} {
+			t.Run(fmt.Sprintf("%q/%s", prefix, name), func(t *testing.T) {
+				if records := collect(reader); !reflect.DeepEqual(records, expected) {
+					t.Errorf("expected %v, got %v", expected, records)
+				}
+				header := reader.Header()
+				if header.Length != base.Header().Length+uint64(len(prefix)) {
+					t.Errorf("expected header length %d, got %d", base.Header().Length+uint64(len(prefix)), header.Length)
+				}
+				if string(header.Raw())+in[header.Length:] != in {
+					t.Error("raw header does not reproduce the input")
+				}
+			})
+		}
+	}
+}
+
+func TestOpenTime(t *testing.T) {
+	var tests = []struct {
+		open    string
+		layouts []string
+		time    time.Time
+		layout  string
+	}{
+		{"2019-01-01-00-00-00", nil, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), ZeekTimeLayout},
+		{"2019-01-01T00:00:00.5Z", nil, time.Date(2019, 1, 1, 0, 0, 0, 5e8, time.UTC), time.RFC3339Nano},
+		{"1546300800.000001", nil, time.Date(2019, 1, 1, 0, 0, 0, 1e3, time.UTC), EpochTimeLayout},
+		{"Jan  1 2019 00:00:00", []string{time.Stamp + " 2006"}, time.Time{}, ""},
+		{"Jan  1 00:00:00 2019", []string{time.Stamp + " 2006"}, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), time.Stamp + " 2006"},
+		{"yesterday", nil, time.Time{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.open, func(t *testing.T) {
+			in := strings.Replace(input, "2019-01-01-00-00-00", tt.open, 1)
+			reader := NewReader(strings.NewReader(in)).WithTimeLayouts(tt.layouts...)
+			header, err := reader.readHeader()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !header.Open.Equal(tt.time) || header.OpenLayout != tt.layout {
+				t.Errorf("got %v (%q), want %v (%q)", header.Open, header.OpenLayout, tt.time, tt.layout)
+			}
+		})
+	}
+}
+
+func TestCloseRecord(t *testing.T) {
+	closeTime := time.Date(2019, 1, 1, 0, 0, 1, 0, time.UTC)
+	for name, in := range map[string]string{
+		"footer":           input,
+		"footer truncated": truncatedInput3,
+	} {
+		t.Run(name, func(t *testing.T) {
+			reader := NewReader(strings.NewReader(in)).WithCloseRecord(true)
+			records := collect(reader)
+			if len(records) != 4 {
+				t.Fatalf("expected 4 records, got %d", len(records))
+			}
+			last := records[len(records)-1]
+			if got, ok := last[CloseTimeField].(time.Time); !ok || !got.Equal(closeTime) {
+				t.Errorf("expected close record with %v, got %v", closeTime, last)
+			}
+			if !reader.Header().Close.Equal(closeTime) {
+				t.Errorf("expected header close time %v, got %v", closeTime, reader.Header().Close)
+			}
+		})
+	}
+
+	if records := collect(NewReader(strings.NewReader(input))); len(records) != 3 {
+		t.Errorf("expected no close record by default, got %d records", len(records))
+	}
+}
+
+func TestProgress(t *testing.T) {
+	reader := NewReader(strings.NewReader(input))
+	if p := reader.Progress(int64(len(input))); p != 0 {
+		t.Errorf("expected no progress before reading, got %v", p)
+	}
+	if _, err := reader.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if reader.RecordsRead() != 1 {
+		t.Errorf("expected 1 record read, got %d", reader.RecordsRead())
+	}
+	if reader.BytesRead() <= reader.Header().Length {
+		t.Errorf("expected more than %d bytes read, got %d", reader.Header().Length, reader.BytesRead())
+	}
+	collect(reader)
+	if reader.RecordsRead() != 3 {
+		t.Errorf("expected 3 records read, got %d", reader.RecordsRead())
+	}
+	if reader.BytesRead() != uint64(len(input)) {
+		t.Errorf("expected %d bytes read, got %d", len(input), reader.BytesRead())
+	}
+	if p := reader.Progress(int64(len(input))); p != 1 {
+		t.Errorf("expected complete progress, got %v", p)
+	}
+}
+
+func TestProgressCallback(t *testing.T) {
+	start := strings.Index(input, "1546304400")
+	end := strings.Index(input, "-\t-")
+	data := input[:start] + strings.Repeat(input[start:end], 5000) + input[strings.Index(input, "#close"):]
+
+	var calls []uint64
+	reader := NewReader(strings.NewReader(data)).WithProgress(uint64(len(data)), func(read, total uint64) {
+		if total != uint64(len(data)) {
+			t.Errorf("expected total %d, got %d", len(data), total)
+		}
+		calls = append(calls, read)
+	})
+	if records := collect(reader); len(records) != 5000 {
+		t.Fatalf("expected 5000 records, got %d", len(records))
+	}
+	if n := len(data)/progressInterval + 2; len(calls) > n || len(calls) < n-1 {
+		t.Errorf("expected about %d calls, got %d", n, len(calls))
+	}
+	for i := 1; i < len(calls)-1; i++ {
+		if calls[i]-calls[i-1] < progressInterval {
+			t.Errorf("calls %d and %d are %d bytes apart", i-1, i, calls[i]-calls[i-1])
+		}
+	}
+	if last := calls[len(calls)-1]; last != uint64(len(data)) {
+		t.Errorf("expected a final call at %d, got %d", len(data), last)
+	}
+}
+
+func TestPeek(t *testing.T) {
+	reader := NewReader(strings.NewReader(input))
+	peeked, err := reader.Peek()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := reader.Peek(); !reflect.DeepEqual(again, peeked) {
+		t.Errorf("expected repeated peek to return %v, got %v", peeked, again)
+	}
+	if reader.BytesRead() != reader.Header().Length || reader.RecordsRead() != 0 {
+		t.Errorf("expected peeked record not to be counted, got offset %d and %d records", reader.BytesRead(), reader.RecordsRead())
+	}
+	record, err := reader.Read()
+	if err != nil || !reflect.DeepEqual(record, peeked) {
+		t.Errorf("expected read to return the peeked record, got %v, %v", record, err)
+	}
+	if reader.BytesRead() <= reader.Header().Length || reader.RecordsRead() != 1 {
+		t.Errorf("expected read record to be counted, got offset %d and %d records", reader.BytesRead(), reader.RecordsRead())
+	}
+
+	if records := collect(reader); len(records) != 2 {
+		t.Errorf("expected 2 remaining records, got %d", len(records))
+	}
+	if _, err := reader.Peek(); err != io.EOF {
+		t.Errorf("expected %v, got %v", io.EOF, err)
+	}
+	if _, err := reader.Read(); err != io.EOF {
+		t.Errorf("expected %v, got %v", io.EOF, err)
+	}
+}
+
+func TestSkip(t *testing.T) {
+	reader := NewReader(strings.NewReader(input))
+	if err := reader.Skip(2); err != nil {
+		t.Fatal(err)
+	}
+	record, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, diffs := RecordsEqual(expected[2], record, NilEqualsMissing()); !ok {
+		t.Errorf("expected third record, got %v", diffs)
+	}
+	if reader.RecordsRead() != 1 {
+		t.Errorf("expected skipped records not to be counted, got %d", reader.RecordsRead())
+	}
+
+	reader = NewBytesReader([]byte(input))
+	if err := reader.Skip(1); err != nil {
+		t.Fatal(err)
+	}
+	first := strings.Index(input, "\n1546304400") + 1
+	second := first + strings.IndexByte(input[first:], '\n') + 1
+	if reader.BytesRead() != uint64(second) {
+		t.Errorf("expected offset %d, got %d", second, reader.BytesRead())
+	}
+	if err := reader.Skip(3); err != io.EOF {
+		t.Errorf("expected %v, got %v", io.EOF, err)
+	}
+	if !reader.Header().Close.Equal(time.Date(2019, 1, 1, 0, 0, 1, 0, time.UTC)) {
+		t.Errorf("expected close time to be read, got %v", reader.Header().Close)
+	}
+}
+
+func TestMaxRecords(t *testing.T) {
+	var tests = []struct {
+		max     uint64
+		records int
+		err     error
+	}{
+		{0, 3, nil},
+		{2, 2, ErrRecordLimit},
+		{3, 3, nil},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.max), func(t *testing.T) {
+			records, err := NewReader(strings.NewReader(input)).WithMaxRecords(tt.max).ReadAll()
+			if len(records) != tt.records || err != tt.err {
+				t.Errorf("expected %d records and %v, got %d and %v", tt.records, tt.err, len(records), err)
+			}
+		})
+	}
+
+	reader := NewReader(strings.NewReader(input)).WithMaxRecords(1)
+	if records, err := reader.ReadRange(0, uint64(len(input))); len(records) != 1 || err != ErrRecordLimit {
+		t.Errorf("expected 1 record and %v from ReadRange, got %d and %v", ErrRecordLimit, len(records), err)
+	}
+}
+
 var expected = []Record{
 	{
 		"ts":        float64(1546304400.000001),
@@ -113,14 +483,37 @@ var expected = []Record{
 	Record{},
 }
 
+// blankRecord returns the record read from the unset and empty rows of
+// input, which hold no values.
+func blankRecord() Record {
+	record := Record{}
+	for field := range expected[0] {
+		record[field] = nil
+	}
+	return record
+}
+
 var expectedGiant = Record{
 	"ts":  float64(1546304400.000001),
 	"foo": strings.Repeat("a", giantColumnSize),
+	"bar": strings.Repeat("a", giantColumnSize),
 }
 
 func MakeReadTester(input string, expectedOutput []Record, expectedError error) func(t *testing.T) {
+	return makeReadTester(func(s string) *Reader {
+		return NewReader(strings.NewReader(s))
+	}, input, expectedOutput, expectedError)
+}
+
+func MakeBytesReadTester(input string, expectedOutput []Record, expectedError error) func(t *testing.T) {
+	return makeReadTester(func(s string) *Reader {
+		return NewBytesReader([]byte(s))
+	}, input, expectedOutput, expectedError)
+}
+
+func makeReadTester(newReader func(string) *Reader, input string, expectedOutput []Record, expectedError error) func(t *testing.T) {
 	return func(t *testing.T) {
-		reader := NewReader(strings.NewReader(input))
+		reader := newReader(input)
 		actual, actualError := collectWithError(reader)
 		if len(expectedOutput) != len(actual) {
 			t.Errorf("expected %d records, got %d", len(expectedOutput), len(actual))
@@ -154,6 +547,197 @@ func TestRead(t *testing.T) {
 		MakeReadTester(giantInput, []Record{expectedGiant}, io.EOF))
 }
 
+func TestBytesReader(t *testing.T) {
+	t.Run("all ok", MakeBytesReadTester(input, expected, io.EOF))
+	t.Run("line truncated in the middle (on a delimiter)",
+		MakeBytesReadTester(truncatedInput1, []Record{expected[0]}, ErrTruncatedLine))
+	t.Run("line truncated inside the last column",
+		MakeBytesReadTester(truncatedInput2, []Record{expected[0]}, ErrTruncatedLine))
+	t.Run("#close footer line truncated",
+		MakeBytesReadTester(truncatedInput3, expected, io.EOF))
+	t.Run(fmt.Sprintf("line with %d byte column", giantColumnSize),
+		MakeBytesReadTester(giantInput, []Record{expectedGiant}, io.EOF))
+
+	t.Run("rows alias input", func(t *testing.T) {
+		b := []byte(input)
+		row, err := NewBytesParser(b).Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if &row[0][0] != &b[0] {
+			t.Error("expected row to alias input")
+		}
+	})
+
+	t.Run("read range", func(t *testing.T) {
+		first := uint64(strings.Index(input, "-\t-"))
+		records, err := NewBytesReader([]byte(input)).ReadRange(first, uint64(len(input)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 2 {
+			t.Errorf("expected 2 records, got %d", len(records))
+		}
+	})
+}
+
+func TestTeeReader(t *testing.T) {
+	var archive bytes.Buffer
+	reader := NewTeeReader(strings.NewReader(input), &archive)
+	if records := collect(reader); len(records) != 3 {
+		t.Errorf("expected 3 records, got %d", len(records))
+	}
+	if archive.String() != input {
+		t.Error("archive does not match the input")
+	}
+	if _, err := reader.ReadRange(0, 1); err != ErrNotSeekable {
+		t.Errorf("expected %v, got %v", ErrNotSeekable, err)
+	}
+}
+
+func TestTeeReaderTrailingData(t *testing.T) {
+	var archive bytes.Buffer
+	in := input + strings.Repeat("trailing data\n", 1500)
+	reader := NewTeeReader(strings.NewReader(in), &archive)
+	if records := collect(reader); len(records) != 3 {
+		t.Errorf("expected 3 records, got %d", len(records))
+	}
+	if err := reader.Drain(); err != nil {
+		t.Fatal(err)
+	}
+	if archive.String() != in {
+		t.Errorf("expected %d archived bytes, got %d", len(in), archive.Len())
+	}
+}
+
+func TestTeeReaderStream(t *testing.T) {
+	var archive bytes.Buffer
+	pr, pw := io.Pipe()
+	go pw.Write([]byte(input))
+	reader := NewTeeReader(pr, &archive)
+	done := make(chan []Record)
+	go func() { done <- collect(reader) }()
+	select {
+	case records := <-done:
+		if len(records) != 3 {
+			t.Errorf("expected 3 records, got %d", len(records))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the footer to end the records of an open stream")
+	}
+
+	go func() {
+		pw.Write([]byte("trailing data\n"))
+		pw.Close()
+	}()
+	if err := reader.Drain(); err != nil {
+		t.Fatal(err)
+	}
+	if archive.String() != input+"trailing data\n" {
+		t.Errorf("expected the trailing data to be archived, got %q", archive.String())
+	}
+}
+
+// lineSource is a LineSource over a slice of lines.
+type lineSource []string
+
+func (s *lineSource) ReadLine() ([]byte, error) {
+	if len(*s) == 0 {
+		return nil, io.EOF
+	}
+	line := (*s)[0]
+	*s = (*s)[1:]
+	return []byte(line), nil
+}
+
+func TestSourceReader(t *testing.T) {
+	src := lineSource(strings.SplitAfter(input, "\n"))
+	reader := NewSourceReader(&src)
+	if records := collect(reader); len(records) != 3 {
+		t.Errorf("expected 3 records, got %d", len(records))
+	}
+	if _, err := reader.ReadRange(0, 1); err != ErrNotSeekable {
+		t.Errorf("expected %v, got %v", ErrNotSeekable, err)
+	}
+}
+
+func TestColumnSpan(t *testing.T) {
+	parser := NewParser(strings.NewReader("a\tbb\t\tccc\r\n"))
+	row, err := parser.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, col := range row {
+		start, end, ok := parser.ColumnSpan(i)
+		if !ok || string(parser.Line()[start:end]) != string(col) {
+			t.Errorf("column %d: got span %d:%d (%v), want %q", i, start, end, ok, col)
+		}
+	}
+	if _, _, ok := parser.ColumnSpan(len(row)); ok {
+		t.Error("expected no span past the last column")
+	}
+}
+
+func TestProjection(t *testing.T) {
+	parser := NewParser(strings.NewReader("a\tb\tc\td\na\tb\tc\td\r\n"))
+	parser.SetProjection(2, []bool{true, false, true})
+	for i := 0; i < 2; i++ {
+		row, err := parser.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(row, Row{[]byte("a"), nil, []byte("c"), nil}) {
+			t.Errorf("unexpected row %q", row)
+		}
+	}
+	if parser.Offset() != 17 {
+		t.Errorf("expected the full lines to be consumed, got offset %d", parser.Offset())
+	}
+}
+
+func TestProjectionExtraColumns(t *testing.T) {
+	parser := NewParser(strings.NewReader("a\tb\nc\td\te\tf\n"))
+	parser.SetProjection(5, nil)
+	for _, expected := range []Row{
+		{[]byte("a"), []byte("b")},
+		{[]byte("c"), []byte("d")},
+	} {
+		row, err := parser.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(row, expected) {
+			t.Errorf("expected row %q, got %q", expected, row)
+		}
+	}
+}
+
+func BenchmarkProjection(b *testing.B) {
+	line := strings.Repeat("0123456789\t", 59) + "0123456789\n"
+	data := []byte(strings.Repeat(line, 1000))
+	for _, bm := range []struct {
+		name   string
+		maxCol int
+	}{
+		{"all", -1},
+		{"first3", 2},
+	} {
+		maxCol := bm.maxCol
+		b.Run(bm.name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				parser := NewBytesParser(data)
+				parser.SetProjection(maxCol, nil)
+				for {
+					if _, err := parser.Read(); err != nil {
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
 func TestReadFieldType(t *testing.T) {
 	var tests = []struct {
 		in  string
@@ -195,6 +779,74 @@ func TestReadFieldType(t *testing.T) {
 	}
 }
 
+var malformedFieldTypes = []string{
+	"", "vector[", "vector]string[", "[]", "[string]", "vector[]", "vector[string]extra",
+	"vector[[string]]", "vector[string", "vec]tor[string]", "string]", "]", "[", "strin",
+}
+
+func TestReadFieldTypeMalformed(t *testing.T) {
+	for _, in := range malformedFieldTypes {
+		_, err := readFieldType(in)
+		if err != (ErrorInvalidFieldType{TypeName: in}) {
+			t.Errorf("%q: expected an invalid field type error, got %v", in, err)
+		}
+	}
+}
+
+func FuzzReadFieldType(f *testing.F) {
+	for _, in := range malformedFieldTypes {
+		f.Add(in)
+	}
+	f.Add("vector[string]")
+	f.Add("set[addr]")
+	f.Fuzz(func(t *testing.T, in string) {
+		ft, err := readFieldType(in)
+		if err != nil {
+			if err != (ErrorInvalidFieldType{TypeName: in}) {
+				t.Fatalf("%q: error does not hold the type name: %v", in, err)
+			}
+			return
+		}
+		// A valid type keeps its meaning when named again.
+		again, err := readFieldType(fieldTypeName(&Header{Types: []FieldType{ft}}, 0))
+		if err != nil || again != ft {
+			t.Errorf("%q: type does not survive naming, got %v (%v)", in, again, err)
+		}
+	})
+}
+
+func TestTypeOf(t *testing.T) {
+	reader := NewReader(strings.NewReader(input))
+	header, err := reader.readHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		field string
+		out   FieldType
+		ok    bool
+	}{
+		{"ts", FieldType{dataType: Time}, true},
+		{"domains", FieldType{dataType: String, container: true}, true},
+		{"missing", FieldType{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			f, ok := header.TypeOf(tt.field)
+			if f != tt.out || ok != tt.ok {
+				t.Errorf("got %v, %v, want %v, %v", f, ok, tt.out, tt.ok)
+			}
+			container, ok := header.IsContainer(tt.field)
+			if container != tt.out.container || ok != tt.ok {
+				t.Errorf("IsContainer got %v, %v, want %v, %v", container, ok, tt.out.container, tt.ok)
+			}
+		})
+	}
+	if _, ok := (&Header{Fields: []string{"a", "b"}}).TypeOf("b"); ok {
+		t.Error("expected field without a type not to be found")
+	}
+}
+
 func TestTransformKeys(t *testing.T) {
 	xform := func(key string) string {
 		return strings.ReplaceAll(key, ".", "_")
@@ -207,6 +859,61 @@ func TestTransformKeys(t *testing.T) {
 	if len(expected[0]) != len(record) {
 		t.Errorf("expected record to have %v fields, got %v", len(expected[0]), len(record))
 	}
+	header := reader.Header()
+	if _, ok := header.FieldIndex("id_orig_h"); !ok {
+		t.Error("expected transformed field to be indexed")
+	}
+	if _, ok := header.TypeOf("id.orig_h"); ok {
+		t.Error("expected original field name not to be indexed")
+	}
+	if header.Fields[2] != "id_orig_h" || header.OriginalFields()[2] != "id.orig_h" {
+		t.Errorf("expected transformed and original names, got %q and %q", header.Fields[2], header.OriginalFields()[2])
+	}
+}
+
+func TestFieldRename(t *testing.T) {
+	reader := NewReader(strings.NewReader(input)).
+		WithFieldRename(map[string]string{"id.orig_h": "src_ip", "missing": "x"}).
+		WithKeyTransform(strings.ToUpper)
+	record, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record["src_ip"] != "1.1.1.1" || record["UID"] != "CCb2Mx28qOMGD3hxab" {
+		t.Errorf("expected renamed and transformed keys, got %v", record)
+	}
+	if i, ok := reader.Header().FieldIndex("src_ip"); !ok || reader.Header().OriginalFields()[i] != "id.orig_h" {
+		t.Errorf("expected renamed field at the original position, got %d, %v", i, ok)
+	}
+
+	_, err = NewReader(strings.NewReader(input)).WithFieldRename(map[string]string{"uid": "ts"}).Read()
+	if err != (ErrorDuplicateField{Field: "ts"}) {
+		t.Errorf("expected a duplicate field error, got %v", err)
+	}
+	record, err = NewReader(strings.NewReader(input)).WithFieldRename(map[string]string{"uid": "ts", "ts": "uid"}).Read()
+	if err != nil || record["ts"] != "CCb2Mx28qOMGD3hxab" {
+		t.Errorf("expected swapped fields, got %v (%v)", record, err)
+	}
+}
+
+func TestRawColumns(t *testing.T) {
+	in := strings.Replace(input, "a.com,b.com", `a\x2ccom,b.com`, 1)
+	reader := NewReader(strings.NewReader(in)).Unescape(true).WithRawColumns("ts", "domains")
+	record, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record["ts"] != 1546304400.000001 || record["ts_raw"] != "1546304400.000001" {
+		t.Errorf("expected converted and raw ts, got %v and %v", record["ts"], record["ts_raw"])
+	}
+	if record["domains_raw"] != `a\x2ccom,b.com` {
+		t.Errorf("expected raw domains as written, got %v", record["domains_raw"])
+	}
+
+	_, err = NewReader(strings.NewReader(input)).WithRawColumns("missing").Read()
+	if err != (ErrorUnknownField{"missing"}) {
+		t.Errorf("expected unknown field error, got %v", err)
+	}
 }
 
 func TestOmitEmpty(t *testing.T) {
@@ -224,6 +931,282 @@ func TestOmitEmpty(t *testing.T) {
 	}
 }
 
+func TestRecordTransform(t *testing.T) {
+	errDropped := errors.New("dropped")
+	withExtra := func(record Record) Record {
+		record["extra"] = true
+		return record
+	}
+	first := withExtra(Record{})
+	for field, v := range expected[0] {
+		first[field] = v
+	}
+	var tests = []struct {
+		name     string
+		xform    RecordTransform
+		expected []Record
+		err      error
+	}{
+		{
+			name: "modify",
+			xform: func(record Record) (Record, error) {
+				record["extra"] = true
+				return record, nil
+			},
+			expected: []Record{first, withExtra(blankRecord()), withExtra(blankRecord())},
+			err:      io.EOF,
+		},
+		{
+			name: "drop",
+			xform: func(record Record) (Record, error) {
+				if record["ts"] == nil {
+					return nil, nil
+				}
+				return record, nil
+			},
+			expected: expected[:1],
+			err:      io.EOF,
+		},
+		{
+			name: "error",
+			xform: func(record Record) (Record, error) {
+				return nil, errDropped
+			},
+			err: errDropped,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewReader(strings.NewReader(input)).WithRecordTransform(tt.xform)
+			records, err := collectWithError(reader)
+			if len(records) != len(tt.expected) {
+				t.Fatalf("expected %d records, got %d", len(tt.expected), len(records))
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected error %v, got %v", tt.err, err)
+			}
+			for i, record := range records {
+				if !reflect.DeepEqual(record, tt.expected[i]) {
+					t.Errorf("record %d: expected %v, got %v", i, tt.expected[i], record)
+				}
+			}
+		})
+	}
+}
+
+func TestColumns(t *testing.T) {
+	xform := func(key string) string {
+		return strings.ReplaceAll(key, ".", "_")
+	}
+	reader := NewReader(strings.NewReader(input)).WithKeyTransform(xform)
+	// The header is read on demand.
+	columns := reader.Columns()
+	if record, err := reader.Read(); err != nil || record["uid"] != "CCb2Mx28qOMGD3hxab" {
+		t.Fatalf("expected the first record, got %v (%v)", record, err)
+	}
+	if len(columns) != len(expected[0]) {
+		t.Fatalf("expected %d columns, got %d", len(expected[0]), len(columns))
+	}
+	var tests = []struct {
+		idx int
+		out Column
+	}{
+		{0, Column{"ts", "ts", 0, "time", reflect.TypeOf(float64(0)), false}},
+		{2, Column{"id_orig_h", "id.orig_h", 2, "addr", reflect.TypeOf(""), false}},
+		{3, Column{"id_orig_p", "id.orig_p", 3, "port", reflect.TypeOf(uint16(0)), false}},
+		{9, Column{"domains", "domains", 9, "vector[string]", reflect.TypeOf([]interface{}{}), true}},
+	}
+	for _, tt := range tests {
+		if columns[tt.idx] != tt.out {
+			t.Errorf("got %v, want %v", columns[tt.idx], tt.out)
+		}
+	}
+}
+
+func TestColumnsManualHeader(t *testing.T) {
+	ft, err := ParseFieldType("count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Header{Fields: []string{"n"}, Types: []FieldType{ft}}
+	h.AppendField("m", ft)
+	columns := h.Columns()
+	if len(columns) != 2 || columns[0].Type != "count" || columns[1].Type != "count" {
+		t.Errorf("unexpected columns %v", columns)
+	}
+}
+
+func TestRegisterConverter(t *testing.T) {
+	t.Cleanup(func() { ValueConverters = DefaultConverters() })
+	RegisterConverter(Count, reflect.TypeOf(""), func(b []byte) (interface{}, error) {
+		return string(b), nil
+	})
+	reader := NewReader(strings.NewReader(input))
+	record, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record["bytes"] != "1001" {
+		t.Errorf("expected the registered converter, got %#v", record["bytes"])
+	}
+	column := reader.Columns()[6]
+	if column.GoType != reflect.TypeOf("") {
+		t.Errorf("expected the registered type, got %v", column.GoType)
+	}
+
+	// A converter assigned directly has no known type.
+	ValueConverters[Count] = func(b []byte) (interface{}, error) { return b, nil }
+	reader = NewReader(strings.NewReader(input))
+	if column = reader.Columns()[6]; column.GoType != nil {
+		t.Errorf("expected an unknown type, got %v", column.GoType)
+	}
+	records := collect(NewReader(strings.NewReader(input)).WithNumericZeroDefault(true))
+	if len(records) != 3 || records[1]["bytes"] != nil {
+		t.Errorf("expected no zero value of an unknown type, got %v", records)
+	}
+}
+
+func TestECSMapping(t *testing.T) {
+	ecsInput := strings.Replace(input, "#path\ttest", "#path\tconn", 1)
+	xform := func(key string) string {
+		return strings.ReplaceAll(key, ".", "_")
+	}
+	reader := NewReader(strings.NewReader(ecsInput)).WithKeyTransform(xform).WithECSMapping()
+	record, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedECS := Record{
+		"@timestamp":        "2019-01-01T01:00:00.000001Z",
+		"event.id":          "CCb2Mx28qOMGD3hxab",
+		"source.ip":         "1.1.1.1",
+		"source.port":       uint16(80),
+		"network.transport": "udp",
+		"event.duration":    int64(3755453000),
+		"bytes":             uint64(1001),
+		"num":               int64(-10),
+		"orig":              true,
+		"domains":           []interface{}{"a.com", "b.com"},
+		"durations":         []interface{}{float64(1), float64(23.45)},
+	}
+	if !reflect.DeepEqual(record, expectedECS) {
+		t.Errorf("got %v, want %v", record, expectedECS)
+	}
+	if goType := reader.Columns()[0].GoType; goType != reflect.TypeOf("") {
+		t.Errorf("expected @timestamp column to be a string, got %v", goType)
+	}
+}
+
+func TestPreserveNumericText(t *testing.T) {
+	reader := NewReader(strings.NewReader(input)).PreserveNumericText(true)
+	record, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := record["ts"]; v != Number("1546304400.000001") {
+		t.Errorf("got %v (%T), want %q", v, v, "1546304400.000001")
+	}
+	if v := record["durations"]; !reflect.DeepEqual(v, []interface{}{Number("1"), Number("23.45")}) {
+		t.Errorf("got %v", v)
+	}
+	if v := record["bytes"]; v != uint64(1001) {
+		t.Errorf("expected counts to be converted, got %v (%T)", v, v)
+	}
+	b, err := json.Marshal(record["durations"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "[1,23.45]" {
+		t.Errorf("got %s", b)
+	}
+	if goType := reader.Columns()[0].GoType; goType != reflect.TypeOf(Number("")) {
+		t.Errorf("expected ts column to be a Number, got %v", goType)
+	}
+}
+
+func TestTypedContainers(t *testing.T) {
+	reader := NewReader(strings.NewReader(input)).WithTypedContainers(true)
+	record, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := record["durations"]; !reflect.DeepEqual(v, []float64{1, 23.45}) {
+		t.Errorf("expected typed container, got %v (%T)", v, v)
+	}
+	if v := record["domains"]; !reflect.DeepEqual(v, []interface{}{"a.com", "b.com"}) {
+		t.Errorf("expected untyped container, got %v (%T)", v, v)
+	}
+	if typ := reader.Columns()[10].GoType; typ != reflect.TypeOf([]float64(nil)) {
+		t.Errorf("expected column type []float64, got %v", typ)
+	}
+	row, err := reader.Header().Encode(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(row[10]) != "1.000000,23.450000" {
+		t.Errorf("expected typed container to encode, got %q", row[10])
+	}
+
+	record, _ = NewReader(strings.NewReader(input)).WithTypedContainers(true).PreserveNumericText(true).Read()
+	if v := record["durations"]; !reflect.DeepEqual(v, []interface{}{Number("1"), Number("23.45")}) {
+		t.Errorf("expected numeric text container, got %v (%T)", v, v)
+	}
+
+	ports := "#set_separator\t,\n#fields\tports\n#types\tset[port]\n80,65536\n"
+	if _, err = NewReader(strings.NewReader(ports)).WithTypedContainers(true).Read(); !errors.Is(err, ErrConvert) {
+		t.Errorf("expected a conversion error, got %v", err)
+	}
+
+	t.Cleanup(func() { ValueConverters = DefaultConverters() })
+	ValueConverters[Interval] = ToString
+	reader = NewReader(strings.NewReader(input)).WithTypedContainers(true)
+	if record, err = reader.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if v := record["durations"]; !reflect.DeepEqual(v, []interface{}{"1", "23.45"}) {
+		t.Errorf("expected the replaced converter, got %v (%T)", v, v)
+	}
+	if typ := reader.Columns()[10].GoType; typ != reflect.TypeOf([]interface{}(nil)) {
+		t.Errorf("expected column type []interface{}, got %v", typ)
+	}
+}
+
+func TestNumberJSON(t *testing.T) {
+	var tests = []struct {
+		in, json string
+		ok       bool
+	}{
+		{"1.5", "1.5", true},
+		{"-0.000000", "-0.000000", true},
+		{"1.000000e+10", "1.000000e+10", true},
+		{"nan", "null", true},
+		{"inf", "null", true},
+		{"-inf", "null", true},
+		{"+5", "", false},
+		{".5", "", false},
+		{"1.", "", false},
+		{"0x1p3", "", false},
+		{"01", "", false},
+	}
+	for _, tt := range tests {
+		v, err := ToNumber([]byte(tt.in))
+		if (err == nil) != tt.ok {
+			t.Errorf("%q: expected ok %v, got %v", tt.in, tt.ok, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		b, err := json.Marshal(Record{"n": v})
+		if err != nil || string(b) != `{"n":`+tt.json+`}` {
+			t.Errorf("%q: expected %s, got %s (%v)", tt.in, tt.json, b, err)
+		}
+	}
+	if b, err := Number(".5").MarshalJSON(); err != nil || string(b) != "0.5" {
+		t.Errorf("expected 0.5, got %s (%v)", b, err)
+	}
+}
+
 func collect(reader *Reader) (records []Record) {
 	for {
 		record, err := reader.Read()
@@ -246,3 +1229,675 @@ func collectWithError(reader *Reader) (records []Record, err error) {
 	}
 	return
 }
+
+func TestLineNumber(t *testing.T) {
+	reader := NewReader(strings.NewReader(input))
+	for line := uint64(9); line <= 11; line++ {
+		if _, err := reader.Read(); err != nil {
+			t.Fatal(err)
+		}
+		if reader.LineNumber() != line {
+			t.Errorf("expected line %d, got %d", line, reader.LineNumber())
+		}
+	}
+}
+
+func TestReadRange(t *testing.T) {
+	first := uint64(strings.Index(input, "1546304400.000001"))
+	second := uint64(strings.Index(input, "-\t-"))
+	footer := uint64(strings.Index(input, "#close"))
+	all := []Record{expected[0], blankRecord(), blankRecord()}
+	var tests = []struct {
+		name       string
+		start, end uint64
+		expected   []Record
+	}{
+		{"whole file", 0, uint64(len(input)), all},
+		{"data section", first, footer, all},
+		{"start inside a record", first + 1, footer, all[1:]},
+		{"end inside a record", first, second + 1, all[:1]},
+		{"empty range", second, second, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewReader(strings.NewReader(input))
+			records, err := reader.ReadRange(tt.start, tt.end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != len(tt.expected) {
+				t.Fatalf("expected %d records, got %d", len(tt.expected), len(records))
+			}
+			for i := range records {
+				if !reflect.DeepEqual(records[i], tt.expected[i]) {
+					t.Errorf("record %d mismatch. expected %v, got %v", i, tt.expected[i], records[i])
+				}
+			}
+		})
+	}
+
+	t.Run("read continues after range", func(t *testing.T) {
+		reader := NewReader(strings.NewReader(input))
+		if _, err := reader.ReadRange(first, second); err != nil {
+			t.Fatal(err)
+		}
+		if reader.LineNumber() != 1 {
+			t.Errorf("expected line 1, got %d", reader.LineNumber())
+		}
+		if _, err := reader.Read(); err != nil || reader.LineNumber() != 2 {
+			t.Errorf("expected line 2, got %d (%v)", reader.LineNumber(), err)
+		}
+		records, err := collectWithError(reader)
+		if err != io.EOF {
+			t.Fatal(err)
+		}
+		if len(records) != 1 {
+			t.Errorf("expected 1 record, got %d", len(records))
+		}
+	})
+
+	t.Run("after peek", func(t *testing.T) {
+		reader := NewReader(strings.NewReader(input))
+		if _, err := reader.Peek(); err != nil {
+			t.Fatal(err)
+		}
+		records, err := reader.ReadRange(first, footer)
+		if err != nil || len(records) != 3 {
+			t.Fatalf("expected 3 records, got %d (%v)", len(records), err)
+		}
+		if reader.RecordsRead() != 3 {
+			t.Errorf("expected 3 records read, got %d", reader.RecordsRead())
+		}
+		want := FlagCounts{Unset: 1, Empty: 1, Populated: 1}
+		if counts := reader.FlagCounts(); counts != want {
+			t.Errorf("expected flag counts %+v, got %+v", want, counts)
+		}
+	})
+
+	t.Run("not seekable", func(t *testing.T) {
+		reader := NewReader(bytes.NewBufferString(input))
+		if _, err := reader.ReadRange(0, 1); err != ErrNotSeekable {
+			t.Errorf("expected ErrNotSeekable, got %v", err)
+		}
+	})
+}
+
+func TestInvalidSeparator(t *testing.T) {
+	for _, directive := range []string{
+		`#separator \x23`,
+		`#separator \x0a`,
+		`#separator \x5c`,
+		`#separator \x`,
+		`#separator \x0909`,
+		`#separator`,
+		// A set separator declared before the separator may collide.
+		"#set_separator\t,\n#separator \\x2c",
+	} {
+		data := strings.Replace(input, `#separator \x09`, directive, 1)
+		_, err := NewReader(strings.NewReader(data)).Read()
+		if !errors.Is(err, ErrInvalidSeparator) {
+			t.Errorf("%q: expected %v, got %v", directive, ErrInvalidSeparator, err)
+		}
+	}
+}
+
+func TestSkipComments(t *testing.T) {
+	data := strings.Replace(input, "\n-\t", "\n# annotated\n-\t", 1)
+	data = strings.Replace(data, "\n#close", "\n#note\n#close", 1)
+	records := collect(NewReader(strings.NewReader(data)).WithSkipComments(true))
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+
+	reader := NewReader(strings.NewReader(data)).WithSkipComments(true)
+	if err := reader.Skip(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Read(); err != io.EOF {
+		t.Errorf("expected %v, got %v", io.EOF, err)
+	}
+}
+
+func TestCloseMarker(t *testing.T) {
+	data := strings.Replace(input, "#close", "#end", 1)
+	reader := NewReader(strings.NewReader(data)).WithCloseMarker([]byte("#end"))
+	if records := collect(reader); len(records) != 3 {
+		t.Errorf("expected 3 records, got %d", len(records))
+	}
+	if reader.Header().Close.IsZero() {
+		t.Error("expected the footer time to be read")
+	}
+}
+
+func TestReaderFromBufio(t *testing.T) {
+	buffered := bufio.NewReader(strings.NewReader(input))
+	if magic, err := buffered.Peek(2); err != nil || string(magic) != "#s" {
+		t.Fatalf("unexpected magic %q (%v)", magic, err)
+	}
+	reader := NewReaderFromBufio(buffered)
+	if reader.parser.source.(*readerSource).reader != buffered {
+		t.Error("expected the caller's buffered reader to be used")
+	}
+	if records := collect(reader); len(records) != 3 {
+		t.Errorf("expected 3 records, got %d", len(records))
+	}
+}
+
+func TestLongHeaderLine(t *testing.T) {
+	var fields, types, values []string
+	for i := 0; i < 6000; i++ {
+		fields = append(fields, fmt.Sprintf("field_%05d", i))
+		types = append(types, "count")
+		values = append(values, strconv.Itoa(i))
+	}
+	header := "#separator \\x09\n#fields\t" + strings.Join(fields, "\t") + "\n#types\t" + strings.Join(types, "\t") + "\n"
+	row := strings.Join(values, "\t") + "\n"
+	data := header + row + row
+	if len(fields)*12 < 64<<10 {
+		t.Fatal("expected a #fields line over 64KB")
+	}
+	for name, reader := range map[string]*Reader{
+		"unbounded": NewReaderFromBufio(bufio.NewReaderSize(strings.NewReader(data), 4096)),
+		"bounded":   NewReaderFromBufio(bufio.NewReaderSize(strings.NewReader(data), 4096)).WithMaxLineSize(1 << 20),
+	} {
+		for i := 0; i < 2; i++ {
+			record, err := reader.Read()
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if record["field_05999"] != uint64(5999) {
+				t.Errorf("%s: expected field_05999 5999, got %v", name, record["field_05999"])
+			}
+			if offset := uint64(len(header) + i*len(row)); reader.RecordOffset() != offset {
+				t.Errorf("%s: expected record %d at offset %d, got %d", name, i, offset, reader.RecordOffset())
+			}
+		}
+		h := reader.Header()
+		if !reflect.DeepEqual(h.Fields, fields) || len(h.Types) != len(fields) || h.Length != uint64(len(header)) {
+			t.Errorf("%s: unexpected header of %d fields, %d types and length %d", name, len(h.Fields), len(h.Types), h.Length)
+		}
+	}
+}
+
+func TestMemoryBudget(t *testing.T) {
+	headerSize := strings.Index(input, "1546304400")
+	for _, tc := range []struct {
+		name   string
+		reader *Reader
+		ok     int
+	}{
+		{"header", NewReader(strings.NewReader(input)).WithMemoryBudget(headerSize - 1), 0},
+		{"header discarded", NewReader(strings.NewReader(input)).RetainRawHeader(false).WithMemoryBudget(headerSize - 1), 0},
+		{"enum interning", NewReader(strings.NewReader(input)).WithEnumInterning(true).WithMemoryBudget(headerSize + 2), 0},
+		{"interchange", NewInterchangeReader(strings.NewReader("a\tb\nstring\tenum\nx\ty\n")).WithMemoryBudget(10), 0},
+		{"within budget", NewReader(strings.NewReader(input)).WithEnumInterning(true).WithMemoryBudget(headerSize + 3), 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for i := 0; i < tc.ok; i++ {
+				if _, err := tc.reader.Read(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := tc.reader.Read(); tc.ok == 0 && !errors.Is(err, ErrBudgetExceeded) {
+				t.Errorf("expected %v, got %v", ErrBudgetExceeded, err)
+			}
+		})
+	}
+}
+
+func TestConverterFor(t *testing.T) {
+	reader := NewReader(strings.NewReader(input))
+	record, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := reader.Header()
+	for _, field := range []string{"ts", "id.orig_p", "domains", "durations"} {
+		idx, _ := header.FieldIndex(field)
+		v, err := header.ConverterFor(idx)(reader.parser.Current()[idx])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(v, record[field]) {
+			t.Errorf("%s: expected %v, got %v", field, record[field], v)
+		}
+	}
+	if v, err := header.ConverterFor(0)([]byte("-")); v != nil || err != nil {
+		t.Errorf("expected nil for unset, got %v (%v)", v, err)
+	}
+	if header.ConverterFor(len(header.Fields)) != nil {
+		t.Error("expected no converter past the last column")
+	}
+}
+
+func TestUnsetElements(t *testing.T) {
+	data := strings.Replace(input, "a.com,b.com", "a.com,-,b.com", 1)
+	data = strings.Replace(data, "1,23.45", "1,-", 1)
+	for _, tc := range []struct {
+		mode      UnsetElements
+		typed     bool
+		domains   interface{}
+		durations interface{}
+	}{
+		{UnsetElementsLiteral, false, []interface{}{"a.com", "-", "b.com"}, nil},
+		{UnsetElementsNil, false, []interface{}{"a.com", nil, "b.com"}, []interface{}{1.0, nil}},
+		{UnsetElementsSkip, false, []interface{}{"a.com", "b.com"}, []interface{}{1.0}},
+		{UnsetElementsNil, true, []interface{}{"a.com", nil, "b.com"}, []float64{1}},
+	} {
+		reader := NewReader(strings.NewReader(data)).WithUnsetElements(tc.mode).WithTypedContainers(tc.typed)
+		record, err := reader.Read()
+		if tc.durations == nil {
+			// "-" is not a valid interval.
+			if err == nil {
+				t.Errorf("mode %d: expected an error converting an unset interval", tc.mode)
+			}
+			reader = NewReader(strings.NewReader(strings.Replace(data, "1,-", "1", 1))).WithUnsetElements(tc.mode)
+			record, err = reader.Read()
+		}
+		if err != nil {
+			t.Fatalf("mode %d: %v", tc.mode, err)
+		}
+		if !reflect.DeepEqual(record["domains"], tc.domains) {
+			t.Errorf("mode %d: expected domains %v, got %v", tc.mode, tc.domains, record["domains"])
+		}
+		if tc.durations != nil && !reflect.DeepEqual(record["durations"], tc.durations) {
+			t.Errorf("mode %d: expected durations %v, got %v", tc.mode, tc.durations, record["durations"])
+		}
+	}
+}
+
+func TestMaxLineSize(t *testing.T) {
+	huge := strings.Repeat("x", 1<<20)
+	data := strings.Replace(input, "\n-\t", "\n"+huge+"\t", 1)
+	for _, reader := range []*Reader{
+		NewReader(strings.NewReader(data)).WithMaxLineSize(256),
+		NewBytesReader([]byte(data)).WithMaxLineSize(256),
+	} {
+		if _, err := reader.Read(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := reader.Read(); err != ErrLineTooLong {
+			t.Errorf("expected %v, got %v", ErrLineTooLong, err)
+		}
+	}
+
+	reader := NewReader(strings.NewReader(input)).WithMaxLineSize(256)
+	if records, err := reader.ReadAll(); err != nil || len(records) != 3 {
+		t.Errorf("expected 3 records, got %d, %v", len(records), err)
+	}
+}
+
+func TestColumnCountCheck(t *testing.T) {
+	data := strings.Replace(input, "\t-\t-\n", "\t-\t-\t-\n", 1)
+	data = strings.Replace(data, "\t(empty)\n#close", "\n#close", 1)
+
+	// Without the check, the extra column is ignored.
+	reader := NewReader(strings.NewReader(data))
+	if _, err := reader.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Read(); err != nil {
+		t.Errorf("expected the extra column to be ignored, got %v", err)
+	}
+
+	reader = NewReader(strings.NewReader(data)).WithColumnCountCheck(true)
+	if _, err := reader.Read(); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []ErrColumnCount{
+		{Expected: 11, Got: 12, Row: []byte(strings.Repeat("-\t", 11) + "-")},
+		{Expected: 11, Got: 10, Row: []byte(strings.Repeat("(empty)\t", 9) + "(empty)")},
+	} {
+		_, err := reader.Read()
+		if !reflect.DeepEqual(err, expected) {
+			t.Errorf("expected %v, got %v", expected, err)
+		}
+	}
+	if _, err := reader.Read(); err != io.EOF {
+		t.Errorf("expected %v, got %v", io.EOF, err)
+	}
+}
+
+func TestSetSeparatorFor(t *testing.T) {
+	data := strings.Replace(input, "a.com,b.com", "a.com|b,c.com", 1)
+	reader := NewReader(strings.NewReader(data)).WithSetSeparatorFor(String, []byte("|"))
+	record, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []interface{}{"a.com", "b,c.com"}; !reflect.DeepEqual(record["domains"], expected) {
+		t.Errorf("expected domains %v, got %v", expected, record["domains"])
+	}
+	if expected := []interface{}{1.0, 23.45}; !reflect.DeepEqual(record["durations"], expected) {
+		t.Errorf("expected durations %v, got %v", expected, record["durations"])
+	}
+}
+
+func TestResetConverters(t *testing.T) {
+	t.Cleanup(func() { ValueConverters = DefaultConverters() })
+	ValueConverters[String] = func(b []byte) (interface{}, error) {
+		return strings.ToUpper(string(b)), nil
+	}
+
+	record, err := NewReader(strings.NewReader(input)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record["uid"] != "CCB2MX28QOMGD3HXAB" {
+		t.Errorf("expected the overridden converter, got %v", record["uid"])
+	}
+	reader := NewReader(strings.NewReader(input)).WithEnumInterning(true).ResetConverters()
+	if record, err = reader.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if record["uid"] != "CCb2Mx28qOMGD3hxab" {
+		t.Errorf("expected the default converter, got %v", record["uid"])
+	}
+	if reader.enums != nil {
+		t.Error("expected enum interning to be undone")
+	}
+}
+
+func TestResult(t *testing.T) {
+	closeTime := time.Date(2019, 1, 1, 0, 0, 1, 0, time.UTC)
+	for name, tc := range map[string]struct {
+		input  string
+		result ReadResult
+	}{
+		"all ok": {input, ReadResult{
+			FooterSeen: true, CloseTime: closeTime, RecordsRead: 3,
+			BytesRead: uint64(len(input)), LastOffset: uint64(strings.Index(input, "#close")),
+		}},
+		"line truncated on a delimiter": {truncatedInput1, ReadResult{
+			Truncated: true, RecordsRead: 1,
+			BytesRead: uint64(len(truncatedInput1)), LastOffset: uint64(strings.LastIndex(truncatedInput1, "\n") + 1),
+		}},
+		"line truncated inside the last column": {truncatedInput2, ReadResult{
+			Truncated: true, RecordsRead: 1,
+			BytesRead: uint64(len(truncatedInput2)), LastOffset: uint64(strings.LastIndex(truncatedInput2, "\n") + 1),
+		}},
+		"footer truncated": {truncatedInput3, ReadResult{
+			FooterSeen: true, CloseTime: closeTime, RecordsRead: 3,
+			BytesRead: uint64(len(truncatedInput3)), LastOffset: uint64(strings.Index(truncatedInput3, "#close")),
+		}},
+	} {
+		for _, reader := range []*Reader{
+			NewReader(strings.NewReader(tc.input)),
+			NewBytesReader([]byte(tc.input)),
+		} {
+			collect(reader)
+			if result := reader.Result(); !reflect.DeepEqual(result, tc.result) {
+				t.Errorf("%s: expected %+v, got %+v", name, tc.result, result)
+			}
+		}
+	}
+}
+
+func TestColumnConverter(t *testing.T) {
+	data := strings.Replace(input, "CCb2Mx28qOMGD3hxab", `{"a":[1,2],"b":"c d"}`, 1)
+	reader := NewReader(strings.NewReader(data)).WithColumnConverter("uid", ToJSONRaw)
+	record, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"uid":{"a":[1,2],"b":"c d"}`) {
+		t.Errorf("expected uid to be nested, got %s", b)
+	}
+	if record, err := reader.Read(); err != nil || record["uid"] != nil {
+		t.Errorf("expected an unset uid, got %v, %v", record["uid"], err)
+	}
+	if typ := reader.Columns()[1].GoType; typ != reflect.TypeOf(json.RawMessage(nil)) {
+		t.Errorf("expected column type json.RawMessage, got %v", typ)
+	}
+	upper := func(b []byte) (interface{}, error) { return bytes.ToUpper(b), nil }
+	reader = NewReader(strings.NewReader(input)).WithColumnConverter("uid", upper)
+	if typ := reader.Columns()[1].GoType; typ != nil {
+		t.Errorf("expected an unknown column type, got %v", typ)
+	}
+	reader = NewReader(strings.NewReader(input)).WithTypedColumnConverter("uid", reflect.TypeOf([]byte(nil)), upper)
+	if typ := reader.Columns()[1].GoType; typ != reflect.TypeOf([]byte(nil)) {
+		t.Errorf("expected column type []byte, got %v", typ)
+	}
+
+	reader = NewReader(strings.NewReader(input)).WithColumnConverter("proto", ToJSONRaw)
+	if _, err := reader.Read(); !errors.Is(err, ErrConvert) {
+		t.Errorf("expected %v, got %v", ErrConvert, err)
+	}
+	reader = NewReader(strings.NewReader(input)).WithColumnConverter("nope", ToJSONRaw)
+	if _, err := reader.Read(); err != (ErrorUnknownField{"nope"}) {
+		t.Errorf("expected an unknown field error, got %v", err)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	reader := NewReader(strings.NewReader(input))
+	if _, ok := reader.Duration(); ok {
+		t.Error("expected no duration before the footer")
+	}
+	collect(reader)
+	if d, ok := reader.Duration(); !ok || d != time.Second {
+		t.Errorf("expected %v, got %v, %v", time.Second, d, ok)
+	}
+
+	reader = NewReader(strings.NewReader(truncatedInput1))
+	collect(reader)
+	if _, ok := reader.Duration(); ok {
+		t.Error("expected no duration without a footer")
+	}
+
+	empty := input[:strings.Index(input, "1546304400")] + "#close\t2019-01-01-00-00-01\n"
+	reader = NewReader(strings.NewReader(empty))
+	collect(reader)
+	if d, ok := reader.Duration(); !ok || d != time.Second {
+		t.Errorf("expected %v for a log without records, got %v, %v", time.Second, d, ok)
+	}
+}
+
+func TestPortConversion(t *testing.T) {
+	for in, out := range map[string]string{"80": "80", "080": "80", "65535": "65535"} {
+		data := strings.Replace(input, "1.1.1.1\t80\t", "1.1.1.1\t"+in+"\t", 1)
+		reader := NewReader(strings.NewReader(data))
+		record, err := reader.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		row, err := reader.Header().Encode(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(row[3]) != out {
+			t.Errorf("%s: expected %s, got %s", in, out, row[3])
+		}
+	}
+
+	for _, in := range []string{"65536", "-1", "http"} {
+		_, err := ToUint16([]byte(in))
+		if !errors.Is(err, ErrConvert) || !strings.Contains(err.Error(), strconv.Quote(in)) {
+			t.Errorf("%s: expected %v with the value, got %v", in, ErrConvert, err)
+		}
+	}
+}
+
+func TestNumericZeroDefault(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		reader *Reader
+		zero   Record
+	}{
+		{"default", NewReader(strings.NewReader(input)), Record{
+			"ts": 0.0, "id.orig_p": uint16(0), "duration": 0.0, "bytes": uint64(0), "num": int64(0),
+			"durations": []interface{}{},
+		}},
+		{"typed", NewReader(strings.NewReader(input)).WithTypedContainers(true), Record{
+			"durations": []float64{},
+		}},
+		{"numeric text", NewReader(strings.NewReader(input)).PreserveNumericText(true), Record{
+			"ts": Number("0"), "duration": Number("0"),
+		}},
+	} {
+		records := collect(tc.reader.WithNumericZeroDefault(true))
+		for _, record := range records[1:] {
+			for field, v := range tc.zero {
+				if !reflect.DeepEqual(record[field], v) {
+					t.Errorf("%s: %s: expected %#v, got %#v", tc.name, field, v, record[field])
+				}
+			}
+			for _, field := range []string{"uid", "id.orig_h", "orig", "domains"} {
+				if record[field] != nil {
+					t.Errorf("%s: %s: expected nil, got %#v", tc.name, field, record[field])
+				}
+			}
+		}
+	}
+}
+
+func TestContainerElements(t *testing.T) {
+	types := []string{"string", "time", "addr", "port", "int", "double", "count", "interval", "bool", "enum", "subnet"}
+	values := []string{"a", "1.5", "1.1.1.1", "80", "-1", "2.5", "3", "0.5", "T", "tcp", "10.0.0.0/8"}
+	fields := make([]string, len(types))
+	vectors := make([]string, len(types))
+	columns := make([]string, len(types))
+	for i := range types {
+		fields[i] = types[i] + "s"
+		vectors[i] = "vector[" + types[i] + "]"
+		columns[i] = values[i] + ",-," + values[i] + ",(empty)"
+	}
+	data := "#separator \\x09\n#set_separator\t,\n#empty_field\t(empty)\n#unset_field\t-\n" +
+		"#fields\t" + strings.Join(fields, "\t") + "\n" +
+		"#types\t" + strings.Join(vectors, "\t") + "\n" +
+		strings.Join(columns, "\t") + "\n"
+
+	for _, mode := range []UnsetElements{UnsetElementsNil, UnsetElementsSkip} {
+		record, err := NewReader(strings.NewReader(data)).WithUnsetElements(mode).Read()
+		if err != nil {
+			t.Fatalf("mode %d: %v", mode, err)
+		}
+		for i, field := range fields {
+			elements, ok := record[field].([]interface{})
+			if !ok {
+				t.Errorf("mode %d: %s: expected []interface{}, got %T", mode, field, record[field])
+				continue
+			}
+			want := []int{0, 2}
+			if mode == UnsetElementsNil {
+				if len(elements) != 4 || elements[1] != nil || elements[3] != nil {
+					t.Errorf("mode %d: %s: expected nil sentinel elements, got %v", mode, field, elements)
+					continue
+				}
+			} else if want = []int{0, 1}; len(elements) != 2 {
+				t.Errorf("mode %d: %s: expected 2 elements, got %v", mode, field, elements)
+				continue
+			}
+			for _, j := range want {
+				if typ := reflect.TypeOf(elements[j]); typ != defaultTypes[i] {
+					t.Errorf("mode %d: %s: expected %v elements, got %v", mode, field, defaultTypes[i], typ)
+				}
+			}
+		}
+	}
+}
+
+func TestEnumType(t *testing.T) {
+	plain, err := NewReader(strings.NewReader(input)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, interning := range []bool{false, true} {
+		reader := NewReader(strings.NewReader(input)).WithEnumType(true).WithEnumInterning(interning)
+		record, err := reader.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, ok := record["proto"].(EnumValue); !ok || v != "udp" {
+			t.Errorf("expected EnumValue udp, got %#v", record["proto"])
+		}
+		if _, ok := record["uid"].(string); !ok {
+			t.Errorf("expected a string uid, got %T", record["uid"])
+		}
+		if typ := reader.Columns()[4].GoType; typ != reflect.TypeOf(EnumValue("")) {
+			t.Errorf("expected column type EnumValue, got %v", typ)
+		}
+		a, _ := json.Marshal(plain)
+		b, _ := json.Marshal(record)
+		if string(a) != string(b) {
+			t.Errorf("expected JSON %s, got %s", a, b)
+		}
+		row, err := reader.Header().Encode(record)
+		if err != nil || string(row[4]) != "udp" {
+			t.Errorf("expected udp to be encoded, got %q (%v)", row[4], err)
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	header, records, err := NewReader(strings.NewReader(input)).Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header == nil || header.Path != "test" {
+		t.Errorf("expected the header of path test, got %+v", header)
+	}
+	if len(records) != 3 {
+		t.Errorf("expected 3 records, got %d", len(records))
+	}
+
+	header, records, err = NewReader(strings.NewReader(truncatedInput1)).Dump()
+	if err != ErrTruncatedLine || header == nil || len(records) != 1 {
+		t.Errorf("expected the header and 1 record with %v, got %v, %d records and %v", ErrTruncatedLine, header, len(records), err)
+	}
+}
+
+func TestFormatDetection(t *testing.T) {
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	w.Write([]byte(input))
+	w.Close()
+	for name, tc := range map[string]struct {
+		data string
+		err  error
+	}{
+		"json":          {`{"ts":1546304400.000001,"uid":"CCb2Mx28qOMGD3hxab"}` + "\n", ErrJSONFormatDetected},
+		"json with bom": {"\xef\xbb\xbf\n  {\"ts\":1546304400.000001}\n", ErrJSONFormatDetected},
+		"gzip":          {compressed.String(), ErrCompressedInput},
+	} {
+		if _, err := NewReader(strings.NewReader(tc.data)).Read(); !errors.Is(err, tc.err) {
+			t.Errorf("%s: expected %v, got %v", name, tc.err, err)
+		}
+		if _, err := NewBytesReader([]byte(tc.data)).Read(); !errors.Is(err, tc.err) {
+			t.Errorf("%s: expected %v, got %v", name, tc.err, err)
+		}
+	}
+}
+
+func TestHeaderOnDemand(t *testing.T) {
+	reader := NewReader(strings.NewReader(input))
+	if header := reader.Header(); header == nil || header.Fields[1] != "uid" {
+		t.Fatalf("expected the header before reading, got %+v", header)
+	}
+	records := collect(reader)
+	if len(records) != len(expected) {
+		t.Fatalf("expected %d records, got %d", len(expected), len(records))
+	}
+	for i, record := range records {
+		if eq, diffs := RecordsEqual(expected[i], record, NilEqualsMissing()); !eq {
+			t.Errorf("record %d: %v", i, diffs)
+		}
+	}
+
+	reader = NewReader(strings.NewReader(`#separator \x23` + "\n"))
+	if reader.Header() != nil {
+		t.Error("expected no header")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := reader.Read(); !errors.Is(err, ErrInvalidSeparator) {
+			t.Errorf("expected %v, got %v", ErrInvalidSeparator, err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package tsv
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// LineSource supplies the lines read by a Parser.
+type LineSource interface {
+	// ReadLine returns the next line, including its terminating newline.
+	// A final line lacking its newline is returned along with io.EOF.
+	ReadLine() ([]byte, error)
+}
+
+// LineSeeker is a LineSource that can be repositioned, as required by
+// Reader.ReadRange.
+type LineSeeker interface {
+	LineSource
+	// Seek positions the source so that the next line starts at offset.
+	Seek(offset uint64) error
+}
+
+// linePeeker is implemented by sources that can look ahead without
+// consuming input.
+type linePeeker interface {
+	peek(n int) []byte
+}
+
+// lineLimiter is implemented by sources that can stop reading a line once
+// it exceeds a maximum size, rather than only checking it afterwards.
+type lineLimiter interface {
+	setMaxLine(n int)
+}
+
+// readerSource is the LineSource of NewParser.
+type readerSource struct {
+	r       io.Reader
+	reader  *bufio.Reader
+	maxLine int
+}
+
+// ReadLine accumulates lines longer than the buffer, header lines included,
+// so that they are returned whole whatever the buffer size.
+func (s *readerSource) ReadLine() ([]byte, error) {
+	if s.maxLine <= 0 {
+		return s.reader.ReadBytes('\n')
+	}
+	var line []byte
+	for {
+		frag, err := s.reader.ReadSlice('\n')
+		if len(line)+len(frag) > s.maxLine {
+			return nil, ErrLineTooLong
+		}
+		line = append(line, frag...)
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+func (s *readerSource) setMaxLine(n int) {
+	s.maxLine = n
+}
+
+// Seek seeks the underlying reader, which must be an io.Seeker.
+func (s *readerSource) Seek(offset uint64) error {
+	seeker, ok := s.r.(io.Seeker)
+	if !ok {
+		return ErrNotSeekable
+	}
+	if _, err := seeker.Seek(int64(offset), io.SeekStart); err != nil {
+		return err
+	}
+	s.reader.Reset(s.r)
+	return nil
+}
+
+func (s *readerSource) peek(n int) []byte {
+	b, _ := s.reader.Peek(n)
+	return b
+}
+
+// bytesSource is the LineSource of NewBytesParser. Lines alias data.
+type bytesSource struct {
+	data   []byte
+	offset uint64
+}
+
+func (s *bytesSource) ReadLine() ([]byte, error) {
+	if s.offset >= uint64(len(s.data)) {
+		return nil, io.EOF
+	}
+	rest := s.data[s.offset:]
+	i := bytes.IndexByte(rest, '\n')
+	if i < 0 {
+		s.offset = uint64(len(s.data))
+		return rest, io.EOF
+	}
+	s.offset += uint64(i + 1)
+	return rest[:i+1], nil
+}
+
+func (s *bytesSource) Seek(offset uint64) error {
+	s.offset = offset
+	return nil
+}
+
+func (s *bytesSource) peek(n int) []byte {
+	if s.offset >= uint64(len(s.data)) {
+		return nil
+	}
+	rest := s.data[s.offset:]
+	if len(rest) > n {
+		rest = rest[:n]
+	}
+	return rest
+}
@@ -0,0 +1,48 @@
+package tsv
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// Layouts recognized when parsing the #open and #close header times, in
+// addition to the time package layouts.
+const (
+	// ZeekTimeLayout is the layout zeek uses for #open and #close.
+	ZeekTimeLayout = "2006-01-02-15-04-05"
+	// EpochTimeLayout matches seconds since the Unix epoch.
+	EpochTimeLayout = "epoch"
+)
+
+var defaultTimeLayouts = []string{ZeekTimeLayout, time.RFC3339Nano, EpochTimeLayout}
+
+// WithTimeLayouts configures the reader to try layouts, in order, before
+// the default layouts when parsing the #open and #close times.
+func (r *Reader) WithTimeLayouts(layouts ...string) *Reader {
+	r.timeLayouts = append(layouts[:len(layouts):len(layouts)], defaultTimeLayouts...)
+	return r
+}
+
+// parseTime parses s with the first matching layout, returning the zero
+// time and an empty layout if none matches.
+func (r *Reader) parseTime(s string) (time.Time, string) {
+	layouts := r.timeLayouts
+	if layouts == nil {
+		layouts = defaultTimeLayouts
+	}
+	for _, layout := range layouts {
+		if layout == EpochTimeLayout {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				continue
+			}
+			sec, frac := math.Modf(f)
+			return time.Unix(int64(sec), int64(math.Round(frac*1e6))*1e3).UTC(), layout
+		}
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, layout
+		}
+	}
+	return time.Time{}, ""
+}
@@ -0,0 +1,62 @@
+package tsv
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// JSONOption configures a transcoder created by NewJSONTranscoder.
+type JSONOption func(*jsonTranscoder)
+
+// JSONReaderConfig applies configure to the Reader of the transcoder, as in
+// JSONReaderConfig(func(r *Reader) { r.WithECSMapping() }).
+func JSONReaderConfig(configure func(*Reader)) JSONOption {
+	return func(t *jsonTranscoder) {
+		configure(t.reader)
+	}
+}
+
+// JSONEscapeHTML configures whether '<', '>' and '&' in strings are escaped,
+// which they are not by default.
+func JSONEscapeHTML(on bool) JSONOption {
+	return func(t *jsonTranscoder) {
+		t.encoder.SetEscapeHTML(on)
+	}
+}
+
+// NewJSONTranscoder returns an io.Reader of the records of the zeek log r
+// as newline-delimited JSON. Records are read and encoded as the result is
+// read, one at a time. An error reading or encoding a record is returned
+// once the lines preceding it have been read.
+func NewJSONTranscoder(r io.Reader, opts ...JSONOption) io.Reader {
+	t := &jsonTranscoder{reader: NewReader(r)}
+	t.encoder = json.NewEncoder(&t.buf)
+	t.encoder.SetEscapeHTML(false)
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+type jsonTranscoder struct {
+	reader  *Reader
+	buf     bytes.Buffer
+	encoder *json.Encoder
+	err     error
+}
+
+func (t *jsonTranscoder) Read(p []byte) (int, error) {
+	for t.buf.Len() == 0 && t.err == nil {
+		// Encode writes nothing on failure, so buf only holds whole lines.
+		record, err := t.reader.Read()
+		if err == nil {
+			err = t.encoder.Encode(record)
+		}
+		t.err = err
+	}
+	if t.buf.Len() > 0 {
+		return t.buf.Read(p)
+	}
+	return 0, t.err
+}
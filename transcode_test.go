@@ -0,0 +1,90 @@
+package tsv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// readChunks reads r to the end in chunks of varying odd sizes.
+func readChunks(r io.Reader) ([]byte, error) {
+	var out []byte
+	for i := 0; ; i++ {
+		buf := make([]byte, 1+2*(i%7))
+		n, err := r.Read(buf)
+		out = append(out, buf[:n]...)
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+	}
+}
+
+func TestJSONTranscoder(t *testing.T) {
+	for _, name := range []string{"conn", "dns", "http"} {
+		t.Run(name, func(t *testing.T) {
+			data, err := ioutil.ReadFile("testdata/" + name + ".log")
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := readChunks(NewJSONTranscoder(bytes.NewReader(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			records := collect(NewBytesReader(data))
+
+			scanner := bufio.NewScanner(bytes.NewReader(out))
+			n := 0
+			for scanner.Scan() {
+				var v map[string]interface{}
+				if err := json.Unmarshal(scanner.Bytes(), &v); err != nil {
+					t.Fatalf("line %d: %v", n+1, err)
+				}
+				if len(v) != len(records[n]) {
+					t.Errorf("line %d: expected %d fields, got %d", n+1, len(records[n]), len(v))
+				}
+				n++
+			}
+			if n != len(records) {
+				t.Errorf("expected %d lines, got %d", len(records), n)
+			}
+		})
+	}
+
+	t.Run("options", func(t *testing.T) {
+		f, err := os.Open("testdata/conn.log")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		transcoder := NewJSONTranscoder(f, JSONReaderConfig(func(r *Reader) {
+			r.WithKeyTransform(func(key string) string {
+				return strings.ReplaceAll(key, ".", "_")
+			})
+		}))
+		out, err := readChunks(transcoder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(out, []byte(`"id_orig_h":`)) {
+			t.Error("expected transformed keys")
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		out, err := readChunks(NewJSONTranscoder(strings.NewReader(truncatedInput1)))
+		if err != ErrTruncatedLine {
+			t.Errorf("expected %v, got %v", ErrTruncatedLine, err)
+		}
+		if bytes.Count(out, []byte("\n")) != 1 || !bytes.HasSuffix(out, []byte("\n")) {
+			t.Errorf("expected the first record only, got %q", out)
+		}
+	})
+}
@@ -0,0 +1,163 @@
+// Package transform provides composable wrappers of tsv.RecordReader that
+// limit, map, filter, window by time and reshape the records read.
+package transform
+
+import (
+	"io"
+
+	tsv "github.com/0xcc-labs/zeek-tsv"
+)
+
+// Reader is a tsv.RecordReader that describes its records with a Header, as
+// tsv.Reader does. All readers returned by this package are Readers.
+type Reader interface {
+	tsv.RecordReader
+	Header() *tsv.Header
+}
+
+// header returns the Header of rr, or nil if it has none or rr is not a
+// Reader.
+func header(rr tsv.RecordReader) *tsv.Header {
+	if r, ok := rr.(Reader); ok {
+		return r.Header()
+	}
+	return nil
+}
+
+// Limit returns a Reader of the first n records of rr, which returns io.EOF
+// once they have been read without reading further from rr.
+func Limit(rr tsv.RecordReader, n uint64) Reader {
+	return &limitReader{rr: rr, n: n}
+}
+
+type limitReader struct {
+	rr tsv.RecordReader
+	n  uint64
+}
+
+func (r *limitReader) Read() (tsv.Record, error) {
+	if r.n == 0 {
+		return nil, io.EOF
+	}
+	record, err := r.rr.Read()
+	if err != nil {
+		return nil, err
+	}
+	r.n--
+	return record, nil
+}
+
+func (r *limitReader) Header() *tsv.Header {
+	return header(r.rr)
+}
+
+// Map returns a Reader of the records of rr passed through f. As with a
+// tsv.RecordTransform, f may modify and return the record, return a nil
+// Record to drop it, or return an error, which Read returns.
+func Map(rr tsv.RecordReader, f func(tsv.Record) (tsv.Record, error)) Reader {
+	return &mapReader{rr: rr, f: f}
+}
+
+type mapReader struct {
+	rr tsv.RecordReader
+	f  func(tsv.Record) (tsv.Record, error)
+}
+
+func (r *mapReader) Read() (tsv.Record, error) {
+	for {
+		record, err := r.rr.Read()
+		if err != nil {
+			return nil, err
+		}
+		record, err = r.f(record)
+		if err != nil || record != nil {
+			return record, err
+		}
+	}
+}
+
+func (r *mapReader) Header() *tsv.Header {
+	return header(r.rr)
+}
+
+// Filter returns a Reader of the records of rr for which keep returns true.
+func Filter(rr tsv.RecordReader, keep func(tsv.Record) bool) Reader {
+	return Map(rr, func(record tsv.Record) (tsv.Record, error) {
+		if !keep(record) {
+			return nil, nil
+		}
+		return record, nil
+	})
+}
+
+// DropFields returns a Reader of the records of rr without the named fields.
+// Its Header omits them.
+func DropFields(rr tsv.RecordReader, names ...string) Reader {
+	return &reshapeReader{
+		rr: rr,
+		reshape: func(record tsv.Record) {
+			for _, name := range names {
+				delete(record, name)
+			}
+		},
+		header: func(h *tsv.Header) *tsv.Header {
+			return h.WithoutFields(names...)
+		},
+	}
+}
+
+// RenameFields returns a Reader of the records of rr with the fields named
+// as keys of m renamed to the corresponding values. Its Header reports the
+// new names.
+func RenameFields(rr tsv.RecordReader, m map[string]string) Reader {
+	return &reshapeReader{
+		rr: rr,
+		reshape: func(record tsv.Record) {
+			renamed := make(tsv.Record, len(m))
+			for from, to := range m {
+				if v, ok := record[from]; ok {
+					delete(record, from)
+					renamed[to] = v
+				}
+			}
+			for k, v := range renamed {
+				record[k] = v
+			}
+		},
+		header: func(h *tsv.Header) *tsv.Header {
+			return h.WithRenamedFields(m)
+		},
+	}
+}
+
+// reshapeReader changes the fields of records, and adjusts the Header of
+// its source to match.
+type reshapeReader struct {
+	rr      tsv.RecordReader
+	reshape func(tsv.Record)
+	header  func(*tsv.Header) *tsv.Header
+	source  *tsv.Header
+	adapted *tsv.Header
+}
+
+func (r *reshapeReader) Read() (tsv.Record, error) {
+	record, err := r.rr.Read()
+	if err != nil {
+		return nil, err
+	}
+	r.reshape(record)
+	return record, nil
+}
+
+// Header returns the adjusted Header of the source.
+func (r *reshapeReader) Header() *tsv.Header {
+	h := header(r.rr)
+	if h == nil {
+		return nil
+	}
+	if h != r.source {
+		r.source = h
+		r.adapted = r.header(h)
+	}
+	return r.adapted
+}
@@ -0,0 +1,192 @@
+package transform
+
+import (
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	tsv "github.com/0xcc-labs/zeek-tsv"
+)
+
+func openConn(t *testing.T) *tsv.Reader {
+	f, err := os.Open("../testdata/conn.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return tsv.NewReader(f)
+}
+
+func collect(t *testing.T, rr tsv.RecordReader) []tsv.Record {
+	var records []tsv.Record
+	for {
+		record, err := rr.Read()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	reader := Limit(openConn(t), 2)
+	if records := collect(t, reader); len(records) != 2 {
+		t.Errorf("expected 2 records, got %d", len(records))
+	}
+	if _, err := reader.Read(); err != io.EOF {
+		t.Errorf("expected %v, got %v", io.EOF, err)
+	}
+	if reader.Header() == nil || reader.Header().Path != "conn" {
+		t.Error("expected the header of the source")
+	}
+	if records := collect(t, Limit(openConn(t), 10)); len(records) != 4 {
+		t.Errorf("expected 4 records, got %d", len(records))
+	}
+}
+
+func TestMapFilter(t *testing.T) {
+	tcp := Filter(openConn(t), func(record tsv.Record) bool {
+		return record["proto"] == "tcp"
+	})
+	records := collect(t, Map(tcp, func(record tsv.Record) (tsv.Record, error) {
+		record["tagged"] = true
+		return record, nil
+	}))
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	for _, record := range records {
+		if record["proto"] != "tcp" || record["tagged"] != true {
+			t.Errorf("unexpected record %v", record)
+		}
+	}
+
+	failure := errors.New("failure")
+	reader := Limit(Map(openConn(t), func(tsv.Record) (tsv.Record, error) {
+		return nil, failure
+	}), 1)
+	if _, err := reader.Read(); err != failure {
+		t.Errorf("expected %v, got %v", failure, err)
+	}
+}
+
+func TestDropFields(t *testing.T) {
+	reader := DropFields(Limit(openConn(t), 1), "uid", "tunnel_parents")
+	records := collect(t, reader)
+	if _, ok := records[0]["uid"]; ok {
+		t.Error("expected uid to be dropped")
+	}
+	header := reader.Header()
+	source := reader.(*reshapeReader).source
+	if len(header.Fields) != len(source.Fields)-2 {
+		t.Fatalf("expected %d fields, got %d", len(source.Fields)-2, len(header.Fields))
+	}
+	if len(records[0]) != len(header.Fields) {
+		t.Errorf("expected %d values, got %d", len(header.Fields), len(records[0]))
+	}
+	if _, ok := header.FieldIndex("uid"); ok {
+		t.Error("expected uid to be dropped from the header")
+	}
+	if _, ok := source.FieldIndex("uid"); !ok {
+		t.Error("expected the source header to be unchanged")
+	}
+	columns := header.Columns()
+	if columns[1].Name != "id.orig_h" || columns[1].Type != "addr" {
+		t.Errorf("unexpected column %+v", columns[1])
+	}
+}
+
+func TestRenameFields(t *testing.T) {
+	m := map[string]string{"id.orig_h": "src", "id.resp_h": "dst", "proto": "service", "service": "proto"}
+	reader := RenameFields(openConn(t), m)
+	records := collect(t, reader)
+	if records[0]["src"] != "192.168.1.102" || records[0]["dst"] != "192.168.1.1" {
+		t.Errorf("unexpected record %v", records[0])
+	}
+	if records[0]["service"] != "udp" || records[0]["proto"] != "dhcp" {
+		t.Errorf("expected proto and service to be swapped, got %v", records[0])
+	}
+	header := reader.Header()
+	if !reflect.DeepEqual(header.Fields[2:8], []string{"src", "id.orig_p", "dst", "id.resp_p", "service", "proto"}) {
+		t.Errorf("unexpected fields %v", header.Fields)
+	}
+	if header.OriginalFields()[2] != "id.orig_h" {
+		t.Errorf("expected original field id.orig_h, got %s", header.OriginalFields()[2])
+	}
+	if typ, ok := header.TypeOf("src"); !ok || typ != mustType(t, "addr") {
+		t.Errorf("expected src to be an addr")
+	}
+}
+
+func mustType(t *testing.T, name string) tsv.FieldType {
+	typ, err := tsv.ParseFieldType(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return typ
+}
+
+func TestTimeWindow(t *testing.T) {
+	at := func(sec, usec int64) time.Time {
+		return time.Unix(sec, usec*1e3)
+	}
+	times := func(records []tsv.Record) []float64 {
+		var ts []float64
+		for _, record := range records {
+			ts = append(ts, record["ts"].(float64))
+		}
+		return ts
+	}
+
+	// The window includes from and excludes to.
+	reader := NewTimeWindowReader(openConn(t), "ts", at(1546300801, 337215), at(1546300810, 250001))
+	if ts := times(collect(t, reader)); !reflect.DeepEqual(ts, []float64{1546300801.337215, 1546300805.000512}) {
+		t.Errorf("unexpected records at %v", ts)
+	}
+	if reader.Header() == nil {
+		t.Error("expected the header of the source")
+	}
+
+	// Reading a sorted log ends past the window, missing an out-of-order
+	// tail that Unsorted finds.
+	records := collect(t, openConn(t))
+	records[1]["ts"], records[3]["ts"] = records[3]["ts"], records[1]["ts"]
+	records[0]["ts"] = nil
+	from, to := at(1546300801, 0), at(1546300806, 0)
+	sorted := NewTimeWindowReader(&sliceReader{records: records}, "ts", from, to)
+	if ts := times(collect(t, sorted)); len(ts) != 0 {
+		t.Errorf("expected no records, got %v", ts)
+	}
+	if sorted.Skipped() != 1 {
+		t.Errorf("expected 1 skipped record, got %d", sorted.Skipped())
+	}
+	unsorted := NewTimeWindowReader(&sliceReader{records: records}, "ts", from, to).Unsorted(true)
+	if ts := times(collect(t, unsorted)); !reflect.DeepEqual(ts, []float64{1546300805.000512, 1546300801.337215}) {
+		t.Errorf("unexpected records at %v", ts)
+	}
+
+	bad := NewTimeWindowReader(openConn(t), "uid", from, to)
+	if _, err := bad.Read(); err == nil {
+		t.Error("expected an error for a field that is not a time")
+	}
+}
+
+// sliceReader reads records from a slice.
+type sliceReader struct {
+	records []tsv.Record
+}
+
+func (r *sliceReader) Read() (tsv.Record, error) {
+	if len(r.records) == 0 {
+		return nil, io.EOF
+	}
+	record := r.records[0]
+	r.records = r.records[1:]
+	return record, nil
+}
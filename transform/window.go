@@ -0,0 +1,96 @@
+package transform
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	tsv "github.com/0xcc-labs/zeek-tsv"
+)
+
+// TimeWindowReader is a Reader of the records of a source whose time field
+// lies within a window.
+type TimeWindowReader struct {
+	rr       tsv.RecordReader
+	field    string
+	from, to time.Time
+	unsorted bool
+	done     bool
+	skipped  uint64
+}
+
+// NewTimeWindowReader returns a Reader of the records of rr whose field, a
+// time, is at or after from and before to. Records with the field unset or
+// missing are skipped. The records of rr are taken to be sorted by field,
+// so reading ends at the first record at or after to, unless configured
+// with Unsorted.
+func NewTimeWindowReader(rr tsv.RecordReader, field string, from, to time.Time) *TimeWindowReader {
+	return &TimeWindowReader{rr: rr, field: field, from: from, to: to}
+}
+
+// Unsorted configures the reader to read all records of the source rather
+// than end at the first one past the window.
+func (r *TimeWindowReader) Unsorted(b bool) *TimeWindowReader {
+	r.unsorted = b
+	return r
+}
+
+// Skipped returns the number of records skipped for lacking a time.
+func (r *TimeWindowReader) Skipped() uint64 {
+	return r.skipped
+}
+
+func (r *TimeWindowReader) Read() (tsv.Record, error) {
+	for !r.done {
+		record, err := r.rr.Read()
+		if err != nil {
+			return nil, err
+		}
+		v := record[r.field]
+		if v == nil {
+			r.skipped++
+			continue
+		}
+		t, err := toTime(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.field, err)
+		}
+		if t.Before(r.from) {
+			continue
+		}
+		if !t.Before(r.to) {
+			r.done = !r.unsorted
+			continue
+		}
+		return record, nil
+	}
+	return nil, io.EOF
+}
+
+func (r *TimeWindowReader) Header() *tsv.Header {
+	return header(r.rr)
+}
+
+// toTime converts a time value as returned by tsv.Reader to a time.Time,
+// rounded to the microsecond precision of zeek.
+func toTime(v interface{}) (time.Time, error) {
+	var f float64
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case float64:
+		f = v
+	case tsv.Number:
+		var err error
+		if f, err = strconv.ParseFloat(string(v), 64); err != nil {
+			return time.Time{}, err
+		}
+	default:
+		return time.Time{}, fmt.Errorf("not a time: %T", v)
+	}
+	sec := math.Floor(f)
+	usec := math.Round((f - sec) * 1e6)
+	return time.Unix(int64(sec), int64(usec)*1e3), nil
+}
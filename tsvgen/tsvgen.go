@@ -0,0 +1,328 @@
+// Package tsvgen generates synthetic zeek conn, dns and http logs for
+// benchmarks and load tests. The logs are deterministic for a given Config,
+// and their values follow the distributions and column widths of real logs
+// closely enough for performance numbers to be representative.
+package tsvgen
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	tsv "github.com/0xcc-labs/zeek-tsv"
+)
+
+// Config describes a log to generate.
+type Config struct {
+	Log     string // "conn", "dns" or "http"
+	Rows    int
+	Hosts   int // distinct client addresses, 256 if zero
+	Servers int // distinct server addresses, 64 if zero
+	Domains int // distinct domain names, 128 if zero
+	Seed    int64
+}
+
+// Logs lists the logs that can be generated.
+var Logs = []string{"conn", "dns", "http"}
+
+// start is the time of the first record.
+var start = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// column is a field of a log and a generator of its values.
+type column struct {
+	name     string
+	typeName string
+	value    func(g *generator) interface{}
+}
+
+var schemas = map[string][]column{
+	"conn": append(connID(),
+		column{"proto", "enum", func(g *generator) interface{} { return g.pick("tcp", "tcp", "tcp", "udp", "udp", "icmp") }},
+		column{"service", "string", func(g *generator) interface{} { return g.unset(0.4, g.pick("dns", "http", "ssl", "ssh", "ntp")) }},
+		column{"duration", "interval", func(g *generator) interface{} { return g.unset(0.2, g.exp(2)) }},
+		column{"orig_bytes", "count", func(g *generator) interface{} { return g.unset(0.2, g.bytes(6)) }},
+		column{"resp_bytes", "count", func(g *generator) interface{} { return g.unset(0.2, g.bytes(8)) }},
+		column{"conn_state", "string", func(g *generator) interface{} { return g.pick("SF", "SF", "SF", "S0", "REJ", "RSTO", "OTH", "SHR") }},
+		column{"local_orig", "bool", func(g *generator) interface{} { return g.unset(0.5, g.rng.Intn(2) == 0) }},
+		column{"local_resp", "bool", func(g *generator) interface{} { return g.unset(0.5, g.rng.Intn(2) == 0) }},
+		column{"missed_bytes", "count", func(g *generator) interface{} { return uint64(0) }},
+		column{"history", "string", func(g *generator) interface{} {
+			return g.pick("ShADadfF", "ShADadFf", "S", "D", "Dd", "ShADadR", "^dA")
+		}},
+		column{"orig_pkts", "count", func(g *generator) interface{} { return uint64(1 + g.rng.Intn(40)) }},
+		column{"orig_ip_bytes", "count", func(g *generator) interface{} { return g.bytes(7) }},
+		column{"resp_pkts", "count", func(g *generator) interface{} { return uint64(g.rng.Intn(60)) }},
+		column{"resp_ip_bytes", "count", func(g *generator) interface{} { return g.bytes(9) }},
+		column{"tunnel_parents", "set[string]", func(g *generator) interface{} { return g.unset(0.9, []string{g.uid()}) }},
+	),
+	"dns": append(connID(),
+		column{"proto", "enum", func(g *generator) interface{} { return g.pick("udp", "udp", "udp", "tcp") }},
+		column{"trans_id", "count", func(g *generator) interface{} { return uint64(g.rng.Intn(1 << 16)) }},
+		column{"rtt", "interval", func(g *generator) interface{} { return g.unset(0.1, g.exp(0.02)) }},
+		column{"query", "string", func(g *generator) interface{} { return g.domain() }},
+		column{"qclass", "count", func(g *generator) interface{} { return uint64(1) }},
+		column{"qclass_name", "string", func(g *generator) interface{} { return "C_INTERNET" }},
+		column{"qtype", "count", func(g *generator) interface{} { return g.code(qtypes) }},
+		column{"qtype_name", "string", func(g *generator) interface{} { return qtypes[g.choice].name }},
+		column{"rcode", "count", func(g *generator) interface{} { return g.code(rcodes) }},
+		column{"rcode_name", "string", func(g *generator) interface{} { return rcodes[g.choice].name }},
+		column{"AA", "bool", func(g *generator) interface{} { return g.rng.Intn(10) == 0 }},
+		column{"TC", "bool", func(g *generator) interface{} { return false }},
+		column{"RD", "bool", func(g *generator) interface{} { return true }},
+		column{"RA", "bool", func(g *generator) interface{} { return g.rng.Intn(10) != 0 }},
+		column{"Z", "count", func(g *generator) interface{} { return uint64(0) }},
+		column{"answers", "vector[string]", func(g *generator) interface{} { return g.unset(0.2, g.answers()) }},
+		column{"TTLs", "vector[interval]", func(g *generator) interface{} { return g.unset(0.2, g.ttls()) }},
+		column{"rejected", "bool", func(g *generator) interface{} { return false }},
+	),
+	"http": append(connID(),
+		column{"trans_depth", "count", func(g *generator) interface{} { return uint64(1 + g.rng.Intn(3)) }},
+		column{"method", "string", func(g *generator) interface{} { return g.pick("GET", "GET", "GET", "POST", "HEAD") }},
+		column{"host", "string", func(g *generator) interface{} { return g.domain() }},
+		column{"uri", "string", func(g *generator) interface{} { return g.uri() }},
+		column{"referrer", "string", func(g *generator) interface{} { return g.unset(0.6, "http://"+g.domain()+"/") }},
+		column{"version", "string", func(g *generator) interface{} { return g.pick("1.1", "1.1", "1.0") }},
+		column{"user_agent", "string", func(g *generator) interface{} { return g.pick(userAgents...) }},
+		column{"request_body_len", "count", func(g *generator) interface{} { return g.pick(uint64(0), uint64(0), g.bytes(5)) }},
+		column{"response_body_len", "count", func(g *generator) interface{} { return g.bytes(9) }},
+		column{"status_code", "count", func(g *generator) interface{} { return g.code(statuses) }},
+		column{"status_msg", "string", func(g *generator) interface{} { return statuses[g.choice].name }},
+		column{"tags", "set[enum]", func(g *generator) interface{} { return []string{} }},
+		column{"resp_fuids", "vector[string]", func(g *generator) interface{} { return g.unset(0.3, []string{"F" + g.uid()[1:]}) }},
+		column{"resp_mime_types", "vector[string]", func(g *generator) interface{} {
+			return g.unset(0.3, []string{g.pick("text/html", "text/plain", "image/png", "application/json").(string)})
+		}},
+	),
+}
+
+// named is a code and its name, such as an HTTP status.
+type named struct {
+	code uint64
+	name string
+}
+
+// Codes and names, repeated by frequency.
+var (
+	qtypes   = []named{{1, "A"}, {1, "A"}, {28, "AAAA"}, {5, "CNAME"}, {12, "PTR"}}
+	rcodes   = []named{{0, "NOERROR"}, {0, "NOERROR"}, {0, "NOERROR"}, {3, "NXDOMAIN"}}
+	statuses = []named{{200, "OK"}, {200, "OK"}, {200, "OK"}, {304, "Not Modified"}, {404, "Not Found"}, {301, "Moved Permanently"}}
+)
+
+var userAgents = []interface{}{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/71.0.3578.98 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_14_2) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/12.0.2 Safari/605.1.15",
+	"Mozilla/5.0 (X11; Linux x86_64; rv:64.0) Gecko/20100101 Firefox/64.0",
+	"curl/7.58.0",
+	"Microsoft-CryptoAPI/10.0",
+}
+
+// connID returns the columns common to all logs.
+func connID() []column {
+	return []column{
+		{"ts", "time", func(g *generator) interface{} { return g.ts }},
+		{"uid", "string", func(g *generator) interface{} { return g.uid() }},
+		{"id.orig_h", "addr", func(g *generator) interface{} { return g.hosts[g.zipf(len(g.hosts))] }},
+		{"id.orig_p", "port", func(g *generator) interface{} { return uint16(49152 + g.rng.Intn(16384)) }},
+		{"id.resp_h", "addr", func(g *generator) interface{} { return g.servers[g.zipf(len(g.servers))] }},
+		{"id.resp_p", "port", func(g *generator) interface{} {
+			return g.pick(uint16(443), uint16(443), uint16(443), uint16(80), uint16(53), uint16(53), uint16(22), uint16(123))
+		}},
+	}
+}
+
+// generator generates the values of records.
+type generator struct {
+	rng     *rand.Rand
+	ts      float64
+	choice  int // index of the last code picked
+	hosts   []string
+	servers []string
+	domains []string
+}
+
+func newGenerator(cfg Config) *generator {
+	g := &generator{
+		rng: rand.New(rand.NewSource(cfg.Seed)),
+		ts:  float64(start.Unix()),
+	}
+	for i := 0; i < orDefault(cfg.Hosts, 256); i++ {
+		g.hosts = append(g.hosts, fmt.Sprintf("192.168.%d.%d", i/254%256, 1+i%254))
+	}
+	for i := 0; i < orDefault(cfg.Servers, 64); i++ {
+		g.servers = append(g.servers, fmt.Sprintf("%d.%d.%d.%d", 1+g.rng.Intn(223), g.rng.Intn(256), g.rng.Intn(256), 1+g.rng.Intn(254)))
+	}
+	tlds := []string{"com", "net", "org", "io"}
+	for i := 0; i < orDefault(cfg.Domains, 128); i++ {
+		g.domains = append(g.domains, g.word(3, 12)+"."+tlds[g.rng.Intn(len(tlds))])
+	}
+	return g
+}
+
+func orDefault(n, def int) int {
+	if n <= 0 {
+		return def
+	}
+	return n
+}
+
+// pick returns one of values, chosen uniformly.
+func (g *generator) pick(values ...interface{}) interface{} {
+	return values[g.rng.Intn(len(values))]
+}
+
+// code picks one of codes, returning its code and recording its index so
+// that the next column can return its name.
+func (g *generator) code(codes []named) interface{} {
+	g.choice = g.rng.Intn(len(codes))
+	return codes[g.choice].code
+}
+
+// unset returns nil with probability p, and v otherwise.
+func (g *generator) unset(p float64, v interface{}) interface{} {
+	if g.rng.Float64() < p {
+		return nil
+	}
+	return v
+}
+
+// zipf returns an index below n, favouring small ones as real traffic
+// favours a few busy hosts.
+func (g *generator) zipf(n int) int {
+	return int(math.Pow(g.rng.Float64(), 3) * float64(n))
+}
+
+// exp returns an exponentially distributed interval of the given mean,
+// rounded to microseconds as zeek writes it.
+func (g *generator) exp(mean float64) float64 {
+	return math.Round(g.rng.ExpFloat64()*mean*1e6) / 1e6
+}
+
+// bytes returns a byte count of around 10^digits, spread over a few orders
+// of magnitude.
+func (g *generator) bytes(digits float64) uint64 {
+	return uint64(math.Pow(10, digits/2+g.rng.NormFloat64()))
+}
+
+func (g *generator) uid() string {
+	const chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	b := make([]byte, 18)
+	b[0] = 'C'
+	for i := 1; i < len(b); i++ {
+		b[i] = chars[g.rng.Intn(len(chars))]
+	}
+	return string(b)
+}
+
+func (g *generator) word(min, max int) string {
+	b := make([]byte, min+g.rng.Intn(max-min+1))
+	for i := range b {
+		b[i] = byte('a' + g.rng.Intn(26))
+	}
+	return string(b)
+}
+
+func (g *generator) domain() string {
+	domain := g.domains[g.zipf(len(g.domains))]
+	if g.rng.Intn(2) == 0 {
+		return g.pick("www.", "api.", "cdn.", "mail.").(string) + domain
+	}
+	return domain
+}
+
+func (g *generator) uri() string {
+	parts := make([]string, 1+g.rng.Intn(4))
+	for i := range parts {
+		parts[i] = g.word(2, 10)
+	}
+	uri := "/" + strings.Join(parts, "/")
+	if g.rng.Intn(3) == 0 {
+		uri += "?id=" + fmt.Sprint(g.rng.Intn(100000))
+	}
+	return uri
+}
+
+func (g *generator) answers() []string {
+	answers := make([]string, 1+g.rng.Intn(3))
+	for i := range answers {
+		answers[i] = g.servers[g.rng.Intn(len(g.servers))]
+	}
+	return answers
+}
+
+func (g *generator) ttls() []float64 {
+	ttls := make([]float64, 1+g.rng.Intn(3))
+	for i := range ttls {
+		ttls[i] = float64(g.rng.Intn(3600))
+	}
+	return ttls
+}
+
+// Generate writes the log described by cfg to w.
+func Generate(w io.Writer, cfg Config) error {
+	columns, ok := schemas[cfg.Log]
+	if !ok {
+		return fmt.Errorf("unknown log %q", cfg.Log)
+	}
+	header := &tsv.Header{
+		Separator:    '\t',
+		Unset:        tsv.DefaultUnset,
+		Empty:        tsv.DefaultEmpty,
+		SetSeparator: tsv.DefaultSetSeparator,
+		Path:         cfg.Log,
+	}
+	names := make([]string, len(columns))
+	types := make([]string, len(columns))
+	for i, c := range columns {
+		fieldType, err := tsv.ParseFieldType(c.typeName)
+		if err != nil {
+			return err
+		}
+		header.Fields = append(header.Fields, c.name)
+		header.Types = append(header.Types, fieldType)
+		names[i], types[i] = c.name, c.typeName
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "#separator \\x09\n#set_separator\t%s\n#empty_field\t%s\n#unset_field\t%s\n",
+		header.SetSeparator, header.Empty, header.Unset)
+	fmt.Fprintf(bw, "#path\t%s\n#open\t%s\n", cfg.Log, start.Format(tsv.ZeekTimeLayout))
+	fmt.Fprintf(bw, "#fields\t%s\n#types\t%s\n", strings.Join(names, "\t"), strings.Join(types, "\t"))
+
+	g := newGenerator(cfg)
+	record := make(tsv.Record, len(columns))
+	var row tsv.Row
+	for n := 0; n < cfg.Rows; n++ {
+		g.ts += g.exp(0.01)
+		for _, c := range columns {
+			record[c.name] = c.value(g)
+		}
+		var err error
+		if row, err = tsv.EncodeRow(header, record, row); err != nil {
+			return err
+		}
+		for i, v := range row {
+			if i > 0 {
+				bw.WriteByte('\t')
+			}
+			bw.Write(v)
+		}
+		bw.WriteByte('\n')
+	}
+	end := time.Unix(int64(g.ts)+1, 0).UTC()
+	fmt.Fprintf(bw, "#close\t%s\n", end.Format(tsv.ZeekTimeLayout))
+	return bw.Flush()
+}
+
+// Bytes returns the log described by cfg.
+func Bytes(cfg Config) ([]byte, error) {
+	var b bytes.Buffer
+	if err := Generate(&b, cfg); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,43 @@
+package tsvgen
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	tsv "github.com/0xcc-labs/zeek-tsv"
+)
+
+func TestGenerate(t *testing.T) {
+	for _, log := range Logs {
+		cfg := Config{Log: log, Rows: 500, Seed: 1}
+		data, err := Bytes(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader := tsv.NewBytesReader(data).WithColumnCountCheck(true)
+		records, err := reader.ReadAll()
+		if err != nil {
+			t.Fatalf("%s: %v", log, err)
+		}
+		if len(records) != cfg.Rows {
+			t.Errorf("%s: expected %d records, got %d", log, cfg.Rows, len(records))
+		}
+		if reader.Header().Path != log || !reader.Result().FooterSeen {
+			t.Errorf("%s: expected a header and footer, got %+v", log, reader.Result())
+		}
+
+		again, _ := Bytes(cfg)
+		if !bytes.Equal(data, again) {
+			t.Errorf("%s: expected the same log for the same seed", log)
+		}
+		cfg.Seed++
+		if other, _ := Bytes(cfg); bytes.Equal(data, other) {
+			t.Errorf("%s: expected another log for another seed", log)
+		}
+	}
+
+	if err := Generate(io.Discard, Config{Log: "weird"}); err == nil {
+		t.Error("expected an error for an unknown log")
+	}
+}
@@ -0,0 +1,189 @@
+package tsv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// InferredWriter writes records as a zeek log whose header is inferred from
+// the first record written.
+type InferredWriter struct {
+	w       *bufio.Writer
+	path    string
+	header  *Header
+	given   bool // whether the header was given rather than inferred
+	pending bool // whether the given header remains to be written
+}
+
+// NewInferredWriter creates a writer of a zeek log with the given #path.
+func NewInferredWriter(w io.Writer, path string) *InferredWriter {
+	return &InferredWriter{w: bufio.NewWriter(w), path: path}
+}
+
+// WithHeader configures the writer to write records with the fields of h,
+// in its order, rather than infer a header. Fields of records that are not
+// in h, such as those a header derived with WithFields leaves out, are not
+// written. It must be called before the first record is written.
+func (w *InferredWriter) WithHeader(h *Header) *InferredWriter {
+	w.header = h
+	w.given, w.pending = true, true
+	return w
+}
+
+// Header returns the header, or nil if none was given and no record was
+// written.
+func (w *InferredWriter) Header() *Header {
+	return w.header
+}
+
+// WriteRecord writes rec, preceded by the header if it is the first record.
+// The fields of the header are those of the first record, sorted by name,
+// with types inferred from the Go types of its values, none of which may
+// be nil. Later records may lack fields, which are written unset, but may
+// not hold other fields or values of other types.
+func (w *InferredWriter) WriteRecord(rec Record) error {
+	if w.pending {
+		w.pending = false
+		if err := w.writeHeader(w.header); err != nil {
+			return err
+		}
+	} else if w.header == nil {
+		header, err := inferHeader(rec, w.path)
+		if err != nil {
+			return err
+		}
+		w.header = header
+		if err := w.writeHeader(header); err != nil {
+			return err
+		}
+	}
+	if !w.given {
+		for field := range rec {
+			if _, ok := w.header.FieldIndex(field); !ok {
+				return ErrorUnknownField{Field: field}
+			}
+		}
+	}
+	row, err := w.header.Encode(rec)
+	if err != nil {
+		return err
+	}
+	return w.writeRow(row)
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *InferredWriter) Flush() error {
+	return w.w.Flush()
+}
+
+func (w *InferredWriter) writeHeader(h *Header) error {
+	sep := w.separator()
+	fmt.Fprintf(w.w, "#separator \\x%02x\n", sep)
+	fmt.Fprintf(w.w, "#set_separator%c%s\n", sep, h.SetSeparator)
+	fmt.Fprintf(w.w, "#empty_field%c%s\n", sep, h.Empty)
+	fmt.Fprintf(w.w, "#unset_field%c%s\n", sep, h.Unset)
+	fmt.Fprintf(w.w, "#path%c%s\n", sep, h.Path)
+	names := Row{[]byte("#fields")}
+	types := Row{[]byte("#types")}
+	for i, field := range h.Fields {
+		names = append(names, []byte(field))
+		types = append(types, []byte(fieldTypeName(h, i)))
+	}
+	if err := w.writeRow(names); err != nil {
+		return err
+	}
+	return w.writeRow(types)
+}
+
+// separator returns the column separator of the header, a tab if unset.
+func (w *InferredWriter) separator() byte {
+	if w.header == nil || w.header.Separator == 0 {
+		return '\t'
+	}
+	return w.header.Separator
+}
+
+func (w *InferredWriter) writeRow(row Row) error {
+	sep := w.separator()
+	for i, v := range row {
+		if i > 0 {
+			w.w.WriteByte(sep)
+		}
+		w.w.Write(v)
+	}
+	return w.w.WriteByte('\n')
+}
+
+// inferHeader returns a header describing rec.
+func inferHeader(rec Record, path string) (*Header, error) {
+	h := &Header{
+		Separator:    '\t',
+		Unset:        DefaultUnset,
+		Empty:        DefaultEmpty,
+		SetSeparator: DefaultSetSeparator,
+		Path:         path,
+	}
+	for field := range rec {
+		h.Fields = append(h.Fields, field)
+	}
+	sort.Strings(h.Fields)
+	for _, field := range h.Fields {
+		name, ok := inferTypeName(rec[field])
+		if !ok {
+			return nil, fmt.Errorf("%s: cannot infer the zeek type of %T", field, rec[field])
+		}
+		fieldType, err := readFieldType(name)
+		if err != nil {
+			return nil, err
+		}
+		h.Types = append(h.Types, fieldType)
+		h.typeNames = append(h.typeNames, name)
+	}
+	h.index()
+	return h, nil
+}
+
+// inferTypeName returns the name of the zeek type of values like v.
+func inferTypeName(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return "string", true
+	case float64, Number:
+		return "double", true
+	case uint64:
+		return "count", true
+	case int64:
+		return "int", true
+	case uint16:
+		return "port", true
+	case bool:
+		return "bool", true
+	case time.Time:
+		return "time", true
+	case []string:
+		return "vector[string]", true
+	case []float64:
+		return "vector[double]", true
+	case []uint64:
+		return "vector[count]", true
+	case []int64:
+		return "vector[int]", true
+	case []uint16:
+		return "vector[port]", true
+	case []interface{}:
+		for _, e := range v {
+			if e != nil {
+				name, ok := inferTypeName(e)
+				if !ok || name[0] == 'v' {
+					return "", false
+				}
+				return "vector[" + name + "]", true
+			}
+		}
+		return "vector[string]", true
+	}
+	return "", false
+}
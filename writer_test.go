@@ -0,0 +1,179 @@
+package tsv
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInferredWriter(t *testing.T) {
+	ts := time.Unix(1546304400, 1000).UTC()
+	records := []Record{
+		{
+			"ts":       ts,
+			"uid":      "CCb2Mx28qOMGD3hxab",
+			"port":     uint16(80),
+			"bytes":    uint64(1001),
+			"num":      int64(-10),
+			"ratio":    0.5,
+			"orig":     true,
+			"domains":  []interface{}{"a.com", "b.com"},
+			"counts":   []uint64{1, 2},
+			"comments": []interface{}{},
+		},
+		{"ts": ts, "uid": "C2"},
+	}
+	var buf bytes.Buffer
+	writer := NewInferredWriter(&buf, "test")
+	for _, record := range records {
+		if err := writer.WriteRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "#types\tcount\tvector[string]\tvector[count]\tvector[string]\tint\tbool\tport\tdouble\ttime\tstring\n") {
+		t.Errorf("unexpected header:\n%s", buf.String())
+	}
+
+	expected := []Record{
+		{
+			"ts":       1546304400.000001,
+			"uid":      "CCb2Mx28qOMGD3hxab",
+			"port":     uint16(80),
+			"bytes":    uint64(1001),
+			"num":      int64(-10),
+			"ratio":    0.5,
+			"orig":     true,
+			"domains":  []interface{}{"a.com", "b.com"},
+			"counts":   []interface{}{uint64(1), uint64(2)},
+			"comments": nil,
+		},
+		{"ts": 1546304400.000001, "uid": "C2"},
+	}
+	reader := NewReader(&buf)
+	for i, want := range expected {
+		got, err := reader.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if eq, diffs := RecordsEqual(want, got, NilEqualsMissing()); !eq {
+			t.Errorf("record %d: %v", i, diffs)
+		}
+	}
+	if reader.Header().Path != "test" {
+		t.Errorf("expected path test, got %s", reader.Header().Path)
+	}
+
+	if err := writer.WriteRecord(Record{"extra": "x"}); err != (ErrorUnknownField{Field: "extra"}) {
+		t.Errorf("expected an unknown field error, got %v", err)
+	}
+	if err := writer.WriteRecord(Record{"bytes": "x"}); err == nil {
+		t.Error("expected an error writing a value of another type")
+	}
+	if err := NewInferredWriter(&buf, "test").WriteRecord(Record{"a": nil}); err == nil {
+		t.Error("expected an error inferring the type of nil")
+	}
+}
+
+func TestDerivedHeader(t *testing.T) {
+	reader := NewReader(strings.NewReader(input))
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Header().WithFields([]string{"uid", "nope"}); err != (ErrorUnknownField{"nope"}) {
+		t.Errorf("expected an unknown field error, got %v", err)
+	}
+	if _, err := reader.Header().WithFields([]string{"uid", "uid"}); err != (ErrorDuplicateField{Field: "uid"}) {
+		t.Errorf("expected a duplicate field error, got %v", err)
+	}
+	derived, err := reader.Header().WithFields([]string{"uid", "ts", "id.orig_h", "domains"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ft, err := ParseFieldType("string")
+	if err != nil {
+		t.Fatal(err)
+	}
+	derived.AppendField("orig_cc", ft)
+	if len(reader.Header().Fields) != 11 {
+		t.Errorf("expected the source header to be unchanged, got %v", reader.Header().Fields)
+	}
+
+	var buf bytes.Buffer
+	writer := NewInferredWriter(&buf, "").WithHeader(derived)
+	for _, record := range records {
+		record["orig_cc"] = "NL"
+		if err := writer.WriteRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	back := NewReader(&buf)
+	got, err := back.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back.Header().Fields, []string{"uid", "ts", "id.orig_h", "domains", "orig_cc"}) {
+		t.Errorf("unexpected fields %v", back.Header().Fields)
+	}
+	for i, record := range records {
+		want := Record{}
+		for _, field := range derived.Fields {
+			want[field] = record[field]
+		}
+		if eq, diffs := RecordsEqual(want, got[i], NilEqualsMissing()); !eq {
+			t.Errorf("record %d: %v", i, diffs)
+		}
+	}
+}
+
+func TestWriterSeparator(t *testing.T) {
+	in, err := ioutil.ReadFile("testdata/comma.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := NewReader(bytes.NewReader(in))
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	records[0]["query"] = "a,b;c"
+	records[1]["answers"] = []interface{}{"x;y", "z,"}
+
+	var buf bytes.Buffer
+	writer := NewInferredWriter(&buf, "").WithHeader(reader.Header())
+	for _, record := range records {
+		if err := writer.WriteRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "#separator \\x2c\n#set_separator,;\n") {
+		t.Errorf("expected comma-separated directives, got %q", buf.String()[:40])
+	}
+
+	back := NewReader(&buf).Unescape(true)
+	got, err := back.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(records) {
+		t.Fatalf("expected %d records, got %d", len(records), len(got))
+	}
+	for i, record := range records {
+		if eq, diffs := RecordsEqual(record, got[i]); !eq {
+			t.Errorf("record %d: %v", i, diffs)
+		}
+	}
+}